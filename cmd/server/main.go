@@ -1,44 +1,185 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"merch-store/internal/config"
 	"merch-store/internal/handlers"
+	"merch-store/internal/health"
+	"merch-store/internal/idempotency"
+	"merch-store/internal/metrics"
 	"merch-store/internal/middleware"
+	"merch-store/internal/migrations"
+	"merch-store/internal/models"
+	"merch-store/internal/onboarding"
 	"merch-store/internal/repository"
+	"merch-store/internal/sso"
+	"merch-store/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 )
 
+// tracingFlushTimeout bounds how long exporting the last spans may delay the
+// exit.
+const tracingFlushTimeout = 5 * time.Second
+
 func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
 
+// run returns only after everything it started has stopped, so that its
+// deferred calls close resources in order.
+func run() error {
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// ctx is cancelled by SIGINT or SIGTERM, which starts the shutdown.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.TracesExporter)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
 	}
+	// Spans still buffered are flushed once everything else has stopped.
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			log.Printf("failed to flush traces: %v", err)
+		}
+	}()
 
 	db, err := repository.InitDB(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	// The pool is closed last, after the server and the workers are done
+	// with it.
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("failed to close database: %v", err)
+		}
+	}()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, migrator, os.Args[2:]); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+		return nil
+	}
+	if len(os.Args) > 1 && os.Args[1] == "promote" {
+		if err := runPromote(ctx, repository.NewRepository(db), os.Args[2:]); err != nil {
+			return fmt.Errorf("promote: %w", err)
+		}
+		return nil
+	}
+	keys, err := loadKeys(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load JWT keys: %w", err)
+	}
+	if cfg.MigrateOnStart {
+		if err := migrateOnStart(ctx, migrator); err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
 	}
-	defer db.Close()
 
-	repo := repository.NewRepository(db)
-	handler := handlers.NewHandler(repo, cfg.JWTSecret)
+	appMetrics := metrics.New()
+	appMetrics.RegisterDB(db, "merchstore")
 
-	router := gin.Default()
+	repo := repository.NewRepository(db,
+		repository.WithOnboardingPolicy(cfg.Onboarding),
+		repository.WithMetrics(appMetrics),
+	)
+	tokens := middleware.NewTokens(keys, cfg.JWTIssuer, cfg.JWTAudience)
+	handler := handlers.NewHandler(repo, tokens)
+	if cfg.OIDCIssuerURL != "" {
+		provider, err := newOIDCProvider(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to set up OIDC provider: %w", err)
+		}
+		handler.SetOIDCProvider(provider, cfg.OIDCRedirectURL)
+	}
+	readiness := health.NewReadiness(health.DefaultCheckTimeout,
+		health.DatabaseCheck(db),
+		health.MigrationsCheck(migrator),
+	)
+
+	// Workers are stopped after the server has drained, before the pool is
+	// closed.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	defer func() {
+		stopWorkers()
+		workers.Wait()
+	}()
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		onboarding.NewVestingWorker(repo, onboarding.DefaultVestingInterval).Run(workerCtx)
+	}()
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		idempotency.NewPurgeWorker(repo, cfg.IdempotencyRetention, idempotency.DefaultPurgeInterval).Run(workerCtx)
+	}()
 
+	// Metrics and tracing wrap gin.Recovery, so that a panic is recorded as
+	// the 500 that Recovery responds with.
+	router := gin.New()
+	router.Use(appMetrics.Middleware(), tracing.Middleware(), gin.Logger(), gin.Recovery(), middleware.RequestID(), middleware.ErrorHandler())
+
+	router.GET("/metrics", gin.WrapH(appMetrics.Handler()))
+	router.GET("/healthz", health.Liveness)
+	router.GET("/readyz", readiness.Handler)
+	router.GET("/.well-known/jwks.json", handler.JWKS)
 	router.POST("/api/auth", handler.Auth)
+	router.POST("/api/auth/refresh", handler.Refresh)
+	router.GET("/api/auth/oidc/login", handler.OIDCLogin)
+	router.GET("/api/auth/oidc/callback", handler.OIDCCallback)
+
+	idempotent := middleware.Idempotency(repo, cfg.IdempotencyRetention)
 
 	apiGroup := router.Group("/api")
-	apiGroup.Use(middleware.JWTAuthMiddleware(cfg.JWTSecret))
+	apiGroup.Use(middleware.JWTAuthMiddleware(tokens, repo))
 	{
+		apiGroup.POST("/auth/logout", handler.Logout)
 		apiGroup.GET("/info", handler.GetInfo)
-		apiGroup.POST("/sendCoin", handler.SendCoin)
-		apiGroup.GET("/buy/:item", handler.BuyItem)
+		apiGroup.GET("/transactions", handler.ListTransactions)
+		apiGroup.GET("/merch", handler.ListMerch)
+		apiGroup.POST("/sendCoin", idempotent, handler.SendCoin)
+		apiGroup.GET("/buy/:item", idempotent, handler.BuyItem)
+		apiGroup.POST("/buy/:item", idempotent, handler.BuyItem)
+		apiGroup.GET("/cart", handler.GetCart)
+		apiGroup.POST("/cart/items", handler.AddCartItem)
+		apiGroup.DELETE("/cart/items/:item", handler.RemoveCartItem)
+		apiGroup.POST("/cart/checkout", idempotent, handler.Checkout)
+
+		adminGroup := apiGroup.Group("/admin")
+		adminGroup.Use(middleware.RequireRole(models.RoleFinanceAdmin))
+		{
+			adminGroup.PUT("/employees/:username/role", handler.SetEmployeeRole)
+			adminGroup.GET("/employees/:username/role-changes", handler.ListRoleChanges)
+			adminGroup.GET("/employees/:username/balance-check", handler.CheckBalance)
+			adminGroup.POST("/grants", idempotent, handler.GrantCoins)
+		}
 	}
 
 	srv := &http.Server{
@@ -49,8 +190,61 @@ func main() {
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	log.Println("Server is running on http://localhost:8080")
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("server error: %v", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Println("Server is running on http://localhost:8080")
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server error: %w", err)
+	case <-ctx.Done():
 	}
+	// A second signal kills the process right away.
+	stop()
+	return shutdown(srv, readiness, cfg)
+}
+
+// shutdown stops taking traffic and waits for in-flight requests, so that
+// purchases and transfers are not cut off by a deploy. The server first
+// reports itself unready for ShutdownDelay while still serving, which gives
+// load balancers time to stop routing to it.
+func shutdown(srv *http.Server, readiness *health.Readiness, cfg *config.Config) error {
+	log.Printf("shutting down, draining for up to %s", cfg.ShutdownDelay+cfg.ShutdownTimeout)
+	readiness.SetDraining()
+	time.Sleep(cfg.ShutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to drain connections: %w", err)
+	}
+	log.Println("server stopped")
+	return nil
+}
+
+// loadKeys fails without a signing key file: an ephemeral key would log
+// everyone out on every restart and differ between replicas.
+func loadKeys(cfg *config.Config) (*middleware.KeySet, error) {
+	switch {
+	case cfg.JWTSigningKeyFile != "":
+		return middleware.LoadKeySet(cfg.JWTSigningKeyFile, cfg.JWTVerificationKeyFiles)
+	case cfg.JWTEphemeralKey:
+		log.Println("JWT_EPHEMERAL_KEY is set, signing tokens with an ephemeral key")
+		return middleware.GenerateKeySet()
+	default:
+		return nil, errors.New("JWT_SIGNING_KEY_FILE is not set; set JWT_EPHEMERAL_KEY=true to use a throwaway key in development")
+	}
+}
+
+func newOIDCProvider(cfg *config.Config) (*sso.Provider, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return sso.NewProvider(ctx, sso.Config{
+		IssuerURL:    cfg.OIDCIssuerURL,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  cfg.OIDCRedirectURL,
+	})
 }
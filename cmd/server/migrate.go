@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"merch-store/internal/migrations"
+)
+
+const migrateUsage = "usage: merch-store migrate [up | down [N] | version]"
+
+// runMigrate implements the migrate subcommand:
+//
+//	merch-store migrate up        apply pending migrations
+//	merch-store migrate down [N]  revert the last N migrations, 1 by default
+//	merch-store migrate version   print the database and latest versions
+func runMigrate(ctx context.Context, migrator *migrations.Migrator, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+		applied, err := migrator.Up(ctx)
+		for _, version := range applied {
+			log.Printf("applied migration %d", version)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) == 2 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return errors.New(migrateUsage)
+			}
+		} else if len(args) > 2 {
+			return errors.New(migrateUsage)
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, version := range reverted {
+			log.Printf("reverted migration %d", version)
+		}
+		return err
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("database version %d, latest %d\n", version, migrator.Latest())
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// migrateOnStart brings the schema up to date before the server starts.
+func migrateOnStart(ctx context.Context, migrator *migrations.Migrator) error {
+	applied, err := migrator.Up(ctx)
+	for _, version := range applied {
+		log.Printf("applied migration %d", version)
+	}
+	return err
+}
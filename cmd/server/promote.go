@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"merch-store/internal/models"
+	"merch-store/internal/repository"
+)
+
+const promoteUsage = "usage: merch-store promote <username> <role>"
+
+// runPromote implements the promote subcommand, which sets the role of an
+// employee without going through the API:
+//
+//	merch-store promote alice finance-admin
+//
+// It is how the first finance admin is appointed. The change is recorded in
+// the role history without an acting employee.
+func runPromote(ctx context.Context, repo repository.Repository, args []string) error {
+	if len(args) != 2 {
+		return errors.New(promoteUsage)
+	}
+	username, role := args[0], args[1]
+	if !models.ValidRole(role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+
+	employee, err := repo.GetEmployeeByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("employee %q not found, they have to log in once first", username)
+	}
+	if err != nil {
+		return err
+	}
+	if err := repo.SetEmployeeRole(ctx, 0, employee.ID, role); err != nil {
+		return err
+	}
+	log.Printf("%s is now %s", username, role)
+	return nil
+}
@@ -0,0 +1,131 @@
+// Package apierror describes the errors the API returns to clients. Every
+// error carries an HTTP status and a stable machine-readable code; clients
+// should branch on the code, not on the message.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"merch-store/internal/repository"
+)
+
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeRecipientNotFound    = "recipient_not_found"
+	CodeConflict             = "conflict"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeInsufficientFunds    = "insufficient_funds"
+	CodeInvalidMerch         = "invalid_merch"
+	CodeEmptyCart            = "empty_cart"
+	CodeInvalidRole          = "invalid_role"
+	CodeInvalidRefreshToken  = "invalid_refresh_token"
+	CodeRefreshTokenReused   = "refresh_token_reused"
+	CodeInternal             = "internal_error"
+	CodeUnavailable          = "service_unavailable"
+)
+
+// Error is an error response. Err is the cause; it is logged but never sent
+// to the client.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details map[string]interface{}
+	Err     error
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func InvalidRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message)
+}
+
+func Unavailable(message string) *Error {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails returns a copy of e with details for the client, e.g. the
+// fields that failed validation.
+func (e *Error) WithDetails(details map[string]interface{}) *Error {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// Wrap returns a copy of e caused by err.
+func (e *Error) Wrap(err error) *Error {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+// sentinels maps repository errors to responses. Their messages are safe to
+// show to clients.
+var sentinels = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{repository.ErrNotFound, http.StatusNotFound, CodeNotFound},
+	{repository.ErrInsufficientFunds, http.StatusBadRequest, CodeInsufficientFunds},
+	{repository.ErrInvalidMerch, http.StatusBadRequest, CodeInvalidMerch},
+	{repository.ErrEmptyCart, http.StatusBadRequest, CodeEmptyCart},
+	{repository.ErrInvalidRole, http.StatusBadRequest, CodeInvalidRole},
+	{repository.ErrTokenExpired, http.StatusUnauthorized, CodeInvalidRefreshToken},
+	{repository.ErrSessionRevoked, http.StatusUnauthorized, CodeInvalidRefreshToken},
+	{repository.ErrTokenReused, http.StatusUnauthorized, CodeRefreshTokenReused},
+	{repository.ErrAlreadyExists, http.StatusConflict, CodeConflict},
+}
+
+// From converts any error into an error response. An error caused by the
+// database being unreachable becomes a 503, whatever the handler wrapped it
+// in. Errors that are neither an *Error nor a known repository error become a
+// 500 whose message does not reveal the cause.
+func From(err error) *Error {
+	if repository.IsUnavailable(err) {
+		return Unavailable("service temporarily unavailable").Wrap(err)
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	for _, s := range sentinels {
+		if errors.Is(err, s.err) {
+			return New(s.status, s.code, s.err.Error()).Wrap(err)
+		}
+	}
+	return Internal("internal server error").Wrap(err)
+}
@@ -0,0 +1,49 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/repository"
+)
+
+func TestFrom(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{repository.ErrNotFound, http.StatusNotFound, CodeNotFound},
+		{fmt.Errorf("transfer: %w", repository.ErrInsufficientFunds), http.StatusBadRequest, CodeInsufficientFunds},
+		{repository.ErrTokenReused, http.StatusUnauthorized, CodeRefreshTokenReused},
+		{Forbidden("Insufficient role"), http.StatusForbidden, CodeForbidden},
+		{errors.New("pq: connection refused"), http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tc := range cases {
+		apiErr := From(tc.err)
+		assert.Equal(t, tc.status, apiErr.Status, "ошибка %v", tc.err)
+		assert.Equal(t, tc.code, apiErr.Code, "ошибка %v", tc.err)
+		assert.NotContains(t, apiErr.Message, "pq:", "сообщение не должно раскрывать причину")
+		assert.ErrorIs(t, apiErr, tc.err)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	type Line struct {
+		Amount int `validate:"required"`
+	}
+	type Request struct {
+		ToUser string `validate:"required"`
+		Lines  []Line `validate:"dive"`
+	}
+	err := validator.New().Struct(Request{Lines: []Line{{}}})
+
+	apiErr := Validation(err)
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+	assert.Equal(t, CodeInvalidRequest, apiErr.Code)
+	assert.Equal(t, map[string]string{"toUser": "required", "lines[0].amount": "required"}, apiErr.Details["fields"])
+}
@@ -0,0 +1,42 @@
+package apierror
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation converts an error of c.ShouldBindJSON or c.ShouldBindQuery into
+// a 400 response. Failed fields are listed in details by their JSON name
+// with the rule they broke, e.g. {"fields": {"amount": "gt"}}.
+func Validation(err error) *Error {
+	apiErr := InvalidRequest("invalid request").Wrap(err)
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return apiErr.WithDetails(map[string]interface{}{"reason": err.Error()})
+	}
+	fields := make(map[string]string, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		fields[fieldPath(fieldErr.Namespace())] = fieldErr.Tag()
+	}
+	return apiErr.WithDetails(map[string]interface{}{"fields": fields})
+}
+
+// fieldPath turns a validator namespace such as "GrantRequest.Grants[0].Amount"
+// into "grants[0].amount". Request fields are tagged with their camelCase
+// names, so lowering the first letter gives the JSON name.
+func fieldPath(namespace string) string {
+	parts := strings.Split(namespace, ".")
+	if len(parts) > 1 {
+		parts = parts[1:]
+	}
+	for i, part := range parts {
+		r, size := utf8.DecodeRuneInString(part)
+		parts[i] = string(unicode.ToLower(r)) + part[size:]
+	}
+	return strings.Join(parts, ".")
+}
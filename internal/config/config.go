@@ -2,12 +2,57 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultIdempotencyRetention = 24 * time.Hour
+	defaultJWTIssuer            = "merch-store"
+	defaultJWTAudience          = "merch-store"
+	defaultShutdownDelay        = 5 * time.Second
+	defaultShutdownTimeout      = 30 * time.Second
+	defaultTracesExporter       = "none"
 )
 
 type Config struct {
 	DatabaseURL string
-	JWTSecret   string
+	// MigrateOnStart applies pending migrations when the server starts.
+	// Disable it to run "merch-store migrate up" as a separate deploy step.
+	MigrateOnStart bool
+	// JWTSigningKeyFile is a PEM encoded RSA or Ed25519 private key. The
+	// server refuses to start without it unless JWTEphemeralKey is set.
+	JWTSigningKeyFile string
+	// JWTEphemeralKey lets the server sign tokens with a key generated on
+	// startup, so that tokens stop working after a restart. For development
+	// only.
+	JWTEphemeralKey bool
+	// JWTVerificationKeyFiles are PEM encoded public keys accepted in
+	// addition to the signing key, e.g. the previous key during rotation.
+	JWTVerificationKeyFiles []string
+	// JWTIssuer and JWTAudience are put into issued tokens and required in
+	// verified ones.
+	JWTIssuer            string
+	JWTAudience          string
+	IdempotencyRetention time.Duration
+	Onboarding           OnboardingPolicy
+	// ShutdownDelay is how long the server keeps serving after it reports
+	// itself unready, so that load balancers stop routing to it first.
+	ShutdownDelay time.Duration
+	// ShutdownTimeout bounds how long in-flight requests may take to finish.
+	ShutdownTimeout time.Duration
+	// OIDC login is enabled when OIDCIssuerURL is set.
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	// TracesExporter is where spans are sent: "otlp", "stdout" or "none".
+	// The OTLP exporter reads its endpoint from the standard
+	// OTEL_EXPORTER_OTLP_* variables.
+	TracesExporter string
 }
 
 func LoadConfig() (*Config, error) {
@@ -16,13 +61,115 @@ func LoadConfig() (*Config, error) {
 		return nil, errors.New("DATABASE_URL is not set")
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return nil, errors.New("JWT_SECRET is not set")
+	migrateOnStart := true
+	if value := os.Getenv("MIGRATE_ON_START"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("MIGRATE_ON_START must be true or false")
+		}
+		migrateOnStart = parsed
+	}
+
+	signingKeyFile := os.Getenv("JWT_SIGNING_KEY_FILE")
+	var verificationKeyFiles []string
+	for _, file := range strings.Split(os.Getenv("JWT_VERIFICATION_KEY_FILES"), ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			verificationKeyFiles = append(verificationKeyFiles, file)
+		}
+	}
+	if signingKeyFile == "" && len(verificationKeyFiles) > 0 {
+		return nil, errors.New("JWT_VERIFICATION_KEY_FILES requires JWT_SIGNING_KEY_FILE")
+	}
+	ephemeralKey := false
+	if value := os.Getenv("JWT_EPHEMERAL_KEY"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("JWT_EPHEMERAL_KEY must be true or false")
+		}
+		ephemeralKey = parsed
+	}
+	if ephemeralKey && signingKeyFile != "" {
+		return nil, errors.New("JWT_EPHEMERAL_KEY cannot be combined with JWT_SIGNING_KEY_FILE")
+	}
+
+	idempotencyRetention, err := durationEnv("IDEMPOTENCY_RETENTION", defaultIdempotencyRetention, false)
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownDelay, err := durationEnv("SHUTDOWN_DELAY", defaultShutdownDelay, true)
+	if err != nil {
+		return nil, err
+	}
+	shutdownTimeout, err := durationEnv("SHUTDOWN_TIMEOUT", defaultShutdownTimeout, false)
+	if err != nil {
+		return nil, err
+	}
+
+	jwtIssuer := os.Getenv("JWT_ISSUER")
+	if jwtIssuer == "" {
+		jwtIssuer = defaultJWTIssuer
+	}
+	jwtAudience := os.Getenv("JWT_AUDIENCE")
+	if jwtAudience == "" {
+		jwtAudience = defaultJWTAudience
+	}
+
+	welcomeBonus := os.Getenv("WELCOME_BONUS")
+	if welcomeBonus == "" {
+		welcomeBonus = defaultWelcomeBonus
+	}
+	onboarding, err := ParseOnboardingPolicy(welcomeBonus)
+	if err != nil {
+		return nil, fmt.Errorf("WELCOME_BONUS: %w", err)
+	}
+
+	oidcIssuerURL := os.Getenv("OIDC_ISSUER_URL")
+	oidcClientID := os.Getenv("OIDC_CLIENT_ID")
+	oidcRedirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if oidcIssuerURL != "" && (oidcClientID == "" || oidcRedirectURL == "") {
+		return nil, errors.New("OIDC_ISSUER_URL requires OIDC_CLIENT_ID and OIDC_REDIRECT_URL")
+	}
+
+	tracesExporter := os.Getenv("OTEL_TRACES_EXPORTER")
+	switch tracesExporter {
+	case "":
+		tracesExporter = defaultTracesExporter
+	case "otlp", "stdout", "none":
+	default:
+		return nil, errors.New("OTEL_TRACES_EXPORTER must be otlp, stdout or none")
 	}
 
 	return &Config{
-		DatabaseURL: dbURL,
-		JWTSecret:   jwtSecret,
+		DatabaseURL:             dbURL,
+		MigrateOnStart:          migrateOnStart,
+		JWTSigningKeyFile:       signingKeyFile,
+		JWTEphemeralKey:         ephemeralKey,
+		JWTVerificationKeyFiles: verificationKeyFiles,
+		JWTIssuer:               jwtIssuer,
+		JWTAudience:             jwtAudience,
+		IdempotencyRetention:    idempotencyRetention,
+		ShutdownDelay:           shutdownDelay,
+		ShutdownTimeout:         shutdownTimeout,
+		Onboarding:              onboarding,
+		OIDCIssuerURL:           oidcIssuerURL,
+		OIDCClientID:            oidcClientID,
+		OIDCClientSecret:        os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:         oidcRedirectURL,
+		TracesExporter:          tracesExporter,
 	}, nil
 }
+
+// durationEnv reads a duration from the environment variable name. Zero is
+// accepted only when allowZero is set.
+func durationEnv(name string, fallback time.Duration, allowZero bool) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 || (d == 0 && !allowZero) {
+		return 0, fmt.Errorf("%s must be a positive duration", name)
+	}
+	return d, nil
+}
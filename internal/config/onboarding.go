@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultWelcomeBonus = "1000"
+
+// VestingTranche is a part of the welcome bonus credited After the employee
+// registers. A zero After means the tranche is credited on registration.
+type VestingTranche struct {
+	Amount int
+	After  time.Duration
+}
+
+// OnboardingPolicy describes the coins a new employee receives.
+type OnboardingPolicy struct {
+	Tranches []VestingTranche
+}
+
+// DefaultOnboardingPolicy credits 1000 coins on registration.
+func DefaultOnboardingPolicy() OnboardingPolicy {
+	policy, _ := ParseOnboardingPolicy(defaultWelcomeBonus)
+	return policy
+}
+
+// Immediate returns the number of coins credited on registration.
+func (p OnboardingPolicy) Immediate() int {
+	total := 0
+	for _, t := range p.Tranches {
+		if t.After == 0 {
+			total += t.Amount
+		}
+	}
+	return total
+}
+
+// Deferred returns the tranches credited some time after registration.
+func (p OnboardingPolicy) Deferred() []VestingTranche {
+	var deferred []VestingTranche
+	for _, t := range p.Tranches {
+		if t.After > 0 {
+			deferred = append(deferred, t)
+		}
+	}
+	return deferred
+}
+
+// ParseOnboardingPolicy parses WELCOME_BONUS: a comma separated list of
+// tranches in the form "amount" or "amount@delay", e.g. "500,500@720h" for
+// 500 coins now and 500 more after 30 days. "0" disables the welcome bonus.
+func ParseOnboardingPolicy(value string) (OnboardingPolicy, error) {
+	var policy OnboardingPolicy
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		amountStr, delayStr, hasDelay := strings.Cut(part, "@")
+
+		amount, err := strconv.Atoi(amountStr)
+		if err != nil || amount < 0 {
+			return OnboardingPolicy{}, fmt.Errorf("invalid welcome bonus amount %q", amountStr)
+		}
+
+		var delay time.Duration
+		if hasDelay {
+			delay, err = time.ParseDuration(delayStr)
+			if err != nil || delay < 0 {
+				return OnboardingPolicy{}, fmt.Errorf("invalid welcome bonus delay %q", delayStr)
+			}
+		}
+
+		if amount > 0 {
+			policy.Tranches = append(policy.Tranches, VestingTranche{Amount: amount, After: delay})
+		}
+	}
+	return policy, nil
+}
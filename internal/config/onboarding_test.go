@@ -0,0 +1,49 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseOnboardingPolicy(t *testing.T) {
+	policy, err := ParseOnboardingPolicy("500, 300@720h, 200@1440h")
+	if err != nil {
+		t.Fatalf("Ожидалась ошибка nil, получена: %v", err)
+	}
+
+	if policy.Immediate() != 500 {
+		t.Errorf("Ожидалось 500 монет сразу, получено %d", policy.Immediate())
+	}
+	deferred := policy.Deferred()
+	if len(deferred) != 2 {
+		t.Fatalf("Ожидалось 2 отложенных транша, получено %d", len(deferred))
+	}
+	if deferred[0].Amount != 300 || deferred[0].After != 30*24*time.Hour {
+		t.Errorf("Неверный первый отложенный транш: %+v", deferred[0])
+	}
+}
+
+func TestParseOnboardingPolicy_Disabled(t *testing.T) {
+	policy, err := ParseOnboardingPolicy("0")
+	if err != nil {
+		t.Fatalf("Ожидалась ошибка nil, получена: %v", err)
+	}
+	if len(policy.Tranches) != 0 {
+		t.Errorf("Ожидалось отсутствие траншей, получено %+v", policy.Tranches)
+	}
+}
+
+func TestParseOnboardingPolicy_Invalid(t *testing.T) {
+	for _, value := range []string{"", "abc", "-100", "500@soon", "500@-1h"} {
+		if _, err := ParseOnboardingPolicy(value); err == nil {
+			t.Errorf("Ожидалась ошибка для %q, получена nil", value)
+		}
+	}
+}
+
+func TestDefaultOnboardingPolicy(t *testing.T) {
+	policy := DefaultOnboardingPolicy()
+	if policy.Immediate() != 1000 || len(policy.Deferred()) != 0 {
+		t.Errorf("Ожидалось 1000 монет сразу без отложенных траншей, получено %+v", policy.Tranches)
+	}
+}
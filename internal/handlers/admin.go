@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"merch-store/internal/apierror"
+	"merch-store/internal/middleware"
+	"merch-store/internal/models"
+	"merch-store/internal/repository"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) SetEmployeeRole(c *gin.Context) {
+	type SetRoleRequest struct {
+		Role string `json:"role" binding:"required"`
+	}
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, apierror.Validation(err))
+		return
+	}
+
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
+		return
+	}
+	actorID := current.ID
+
+	employee, err := h.repo.GetEmployeeByUsername(c.Request.Context(), c.Param("username"))
+	if errors.Is(err, repository.ErrNotFound) {
+		middleware.AbortWithError(c, apierror.NotFound("employee not found"))
+		return
+	}
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	if err := h.repo.SetEmployeeRole(c.Request.Context(), actorID, employee.ID, req.Role); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"username": employee.Username,
+		"role":     req.Role,
+	})
+}
+
+func (h *Handler) ListRoleChanges(c *gin.Context) {
+	employee, err := h.repo.GetEmployeeByUsername(c.Request.Context(), c.Param("username"))
+	if errors.Is(err, repository.ErrNotFound) {
+		middleware.AbortWithError(c, apierror.NotFound("employee not found"))
+		return
+	}
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	changes, err := h.repo.ListRoleChanges(c.Request.Context(), employee.ID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	items := []map[string]interface{}{}
+	for _, change := range changes {
+		items = append(items, map[string]interface{}{
+			"oldRole":   change.OldRole,
+			"newRole":   change.NewRole,
+			"changedBy": change.ChangedByUsername,
+			"createdAt": change.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"username": employee.Username,
+		"role":     employee.Role,
+		"changes":  items,
+	})
+}
+
+// CheckBalance compares the balance of an employee with their ledger. A
+// mismatch is reported in the response rather than as an error, since the
+// check itself has succeeded.
+func (h *Handler) CheckBalance(c *gin.Context) {
+	employee, err := h.repo.GetEmployeeByUsername(c.Request.Context(), c.Param("username"))
+	if errors.Is(err, repository.ErrNotFound) {
+		middleware.AbortWithError(c, apierror.NotFound("employee not found"))
+		return
+	}
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	err = h.repo.VerifyBalance(c.Request.Context(), employee.ID)
+	switch {
+	case errors.Is(err, repository.ErrBalanceMismatch):
+		c.JSON(http.StatusOK, gin.H{
+			"username":   employee.Username,
+			"consistent": false,
+			"reason":     err.Error(),
+		})
+	case err != nil:
+		middleware.AbortWithError(c, err)
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"username":   employee.Username,
+			"consistent": true,
+		})
+	}
+}
+
+func (h *Handler) GrantCoins(c *gin.Context) {
+	type GrantLine struct {
+		Username string `json:"username" binding:"required"`
+		// A bound on a single line keeps a typo from minting an arbitrary
+		// number of coins; zero is rejected by required.
+		Amount int `json:"amount" binding:"required,min=-100000,max=100000"`
+	}
+	type GrantRequest struct {
+		Reason string      `json:"reason" binding:"required,max=500"`
+		Grants []GrantLine `json:"grants" binding:"required,min=1,max=1000,dive"`
+	}
+	var req GrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, apierror.Validation(err))
+		return
+	}
+
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
+		return
+	}
+	actorID := current.ID
+
+	grants := make([]models.Grant, 0, len(req.Grants))
+	usernames := make(map[int]string, len(req.Grants))
+	for _, line := range req.Grants {
+		employee, err := h.repo.GetEmployeeByUsername(c.Request.Context(), line.Username)
+		if errors.Is(err, repository.ErrNotFound) {
+			middleware.AbortWithError(c, apierror.NotFound("employee not found").WithDetails(map[string]interface{}{"username": line.Username}))
+			return
+		}
+		if err != nil {
+			middleware.AbortWithError(c, err)
+			return
+		}
+		usernames[employee.ID] = employee.Username
+		grants = append(grants, models.Grant{
+			EmployeeID: employee.ID,
+			Amount:     line.Amount,
+			Reason:     req.Reason,
+		})
+	}
+
+	transactions, err := h.repo.GrantCoins(c.Request.Context(), actorID, grants)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInsufficientFunds):
+			middleware.AbortWithError(c, apierror.New(http.StatusBadRequest, apierror.CodeInsufficientFunds, "clawback exceeds employee balance"))
+		case errors.Is(err, repository.ErrNotFound):
+			middleware.AbortWithError(c, apierror.NotFound("employee not found"))
+		default:
+			middleware.AbortWithError(c, err)
+		}
+		return
+	}
+
+	items := make([]map[string]interface{}, 0, len(transactions))
+	for _, t := range transactions {
+		items = append(items, map[string]interface{}{
+			"username": usernames[t.EmployeeID],
+			"amount":   t.Amount,
+			"type":     t.TransactionType,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reason": req.Reason,
+		"grants": items,
+	})
+}
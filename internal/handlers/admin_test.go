@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/middleware"
+	"merch-store/internal/repository"
+)
+
+func newAdminRouter() *gin.Engine {
+	return newAdminRouterWith(&fakeRepo{})
+}
+
+func newAdminRouterWith(repo repository.Repository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewHandler(repo, testTokens)
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		middleware.SetCurrentEmployee(c, middleware.AuthenticatedEmployee{ID: 2})
+		c.Next()
+	})
+	router.PUT("/api/admin/employees/:username/role", handler.SetEmployeeRole)
+	router.GET("/api/admin/employees/:username/role-changes", handler.ListRoleChanges)
+	router.GET("/api/admin/employees/:username/balance-check", handler.CheckBalance)
+	return router
+}
+
+func TestHandler_SetEmployeeRole(t *testing.T) {
+	router := newAdminRouter()
+
+	set := func(payload string) int {
+		req, _ := http.NewRequest("PUT", "/api/admin/employees/alice/role", bytes.NewBufferString(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, set(`{"role": "store-manager"}`))
+	assert.Equal(t, http.StatusBadRequest, set(`{"role": "emperor"}`))
+	assert.Equal(t, http.StatusBadRequest, set(`{}`))
+}
+
+func TestHandler_ListRoleChanges(t *testing.T) {
+	router := newAdminRouter()
+
+	req, _ := http.NewRequest("GET", "/api/admin/employees/alice/role-changes", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Changes []map[string]interface{} `json:"changes"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Changes, 1) {
+		assert.Equal(t, "boss", resp.Changes[0]["changedBy"])
+		assert.Equal(t, "store-manager", resp.Changes[0]["newRole"])
+	}
+}
+
+func TestHandler_GrantCoins(t *testing.T) {
+	router := newAdminRouter()
+	router.POST("/api/admin/grants", NewHandler(&fakeRepo{}, testTokens).GrantCoins)
+
+	grant := func(payload string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/admin/grants", bytes.NewBufferString(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := grant(`{"reason": "bonus", "grants": [{"username": "alice", "amount": 100}, {"username": "bob", "amount": -20}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Grants []map[string]interface{} `json:"grants"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Grants, 2) {
+		assert.Equal(t, "grant", resp.Grants[0]["type"])
+		assert.Equal(t, "clawback", resp.Grants[1]["type"])
+	}
+
+	// Без причины, с нулевой суммой и с пустым списком запрос некорректен
+	assert.Equal(t, http.StatusBadRequest, grant(`{"grants": [{"username": "alice", "amount": 100}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, grant(`{"reason": "bonus", "grants": [{"username": "alice", "amount": 0}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, grant(`{"reason": "bonus", "grants": []}`).Code)
+	assert.Equal(t, http.StatusBadRequest, grant(`{"reason": "bonus", "grants": [{"username": "alice", "amount": -5000}]}`).Code)
+}
+
+// mismatchRepo сообщает о расхождении баланса с журналом
+type mismatchRepo struct {
+	fakeRepo
+}
+
+func (r *mismatchRepo) VerifyBalance(ctx context.Context, employeeID int) error {
+	return fmt.Errorf("%w: employee %d has 900 coins, ledger says 1000", repository.ErrBalanceMismatch, employeeID)
+}
+
+func TestHandler_CheckBalance(t *testing.T) {
+	check := func(router *gin.Engine) map[string]interface{} {
+		req, _ := http.NewRequest("GET", "/api/admin/employees/alice/balance-check", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := check(newAdminRouter())
+	assert.Equal(t, true, resp["consistent"])
+
+	resp = check(newAdminRouterWith(&mismatchRepo{}))
+	assert.Equal(t, false, resp["consistent"], "расхождение возвращается в ответе, а не ошибкой")
+	assert.Contains(t, resp["reason"], "ledger says 1000")
+}
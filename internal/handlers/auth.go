@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"merch-store/internal/apierror"
+	"merch-store/internal/middleware"
+	"merch-store/internal/models"
+	"merch-store/internal/repository"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refreshTokenTTL is the lifetime of a refresh token. Every refresh issues a
+// new one, so an active client stays logged in indefinitely.
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// startSession opens a new session for the employee and responds with its
+// first access and refresh tokens.
+func (h *Handler) startSession(c *gin.Context, employee models.Employee) {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		middleware.AbortWithError(c, apierror.Internal("cannot start session").Wrap(err))
+		return
+	}
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		middleware.AbortWithError(c, apierror.Internal("cannot start session").Wrap(err))
+		return
+	}
+
+	err = h.repo.CreateSession(c.Request.Context(), employee.ID, sessionID, hashToken(refreshToken), time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		middleware.AbortWithError(c, apierror.Internal("cannot start session").Wrap(err))
+		return
+	}
+
+	h.respondWithTokens(c, employee, sessionID, refreshToken)
+}
+
+func (h *Handler) respondWithTokens(c *gin.Context, employee models.Employee, sessionID, refreshToken string) {
+	token, err := middleware.GenerateJWT(employee.ID, employee.Role, sessionID, h.tokens)
+	if err != nil {
+		middleware.AbortWithError(c, apierror.Internal("cannot generate token").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        token,
+		"refreshToken": refreshToken,
+	})
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. The role is read again, so role changes apply on refresh.
+func (h *Handler) Refresh(c *gin.Context) {
+	type RefreshRequest struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, apierror.Validation(err))
+		return
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		middleware.AbortWithError(c, apierror.Internal("cannot refresh session").Wrap(err))
+		return
+	}
+
+	session, err := h.repo.RotateRefreshToken(c.Request.Context(), hashToken(req.RefreshToken), hashToken(refreshToken), time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTokenReused):
+			middleware.AbortWithError(c, apierror.New(http.StatusUnauthorized, apierror.CodeRefreshTokenReused, "refresh token was already used, session revoked"))
+		case errors.Is(err, repository.ErrNotFound),
+			errors.Is(err, repository.ErrTokenExpired),
+			errors.Is(err, repository.ErrSessionRevoked):
+			middleware.AbortWithError(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidRefreshToken, "invalid refresh token"))
+		default:
+			middleware.AbortWithError(c, apierror.Internal("cannot refresh session").Wrap(err))
+		}
+		return
+	}
+
+	employee, err := h.repo.GetEmployeeByID(c.Request.Context(), session.EmployeeID)
+	if err != nil {
+		middleware.AbortWithError(c, apierror.Internal("cannot refresh session").Wrap(err))
+		return
+	}
+
+	h.respondWithTokens(c, employee, session.ID, refreshToken)
+}
+
+// Logout revokes the session of the access token. The access token and every
+// refresh token of the session stop working immediately.
+func (h *Handler) Logout(c *gin.Context) {
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
+		return
+	}
+	if current.SessionID == "" {
+		middleware.AbortWithError(c, apierror.Unauthorized("session not found in context"))
+		return
+	}
+
+	if err := h.repo.RevokeSession(c.Request.Context(), current.SessionID); err != nil {
+		middleware.AbortWithError(c, apierror.Internal("cannot revoke session").Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// JWKS publishes the public keys access tokens can be verified with.
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.tokens.Keys.JWKS())
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is what the repository stores instead of the refresh token, so a
+// database leak does not leak usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/middleware"
+)
+
+func TestHandler_Refresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewHandler(&fakeRepo{}, testTokens)
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth/refresh", handler.Refresh)
+
+	refresh := func(payload string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/auth/refresh", bytes.NewBufferString(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := refresh(`{"refreshToken": "valid"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]string
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp["token"])
+	assert.NotEmpty(t, resp["refreshToken"])
+	assert.NotEqual(t, "valid", resp["refreshToken"], "refresh токен должен меняться при каждом обновлении")
+
+	assert.Equal(t, http.StatusUnauthorized, refresh(`{"refreshToken": "reused"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, refresh(`{"refreshToken": "unknown"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, refresh(`{}`).Code)
+}
+
+func TestHandler_Logout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewHandler(&fakeRepo{}, testTokens)
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth/logout", func(c *gin.Context) {
+		middleware.SetCurrentEmployee(c, middleware.AuthenticatedEmployee{ID: 1, SessionID: "session-1"})
+		c.Next()
+	}, handler.Logout)
+	router.POST("/api/auth/logout-anonymous", handler.Logout)
+
+	req, _ := http.NewRequest("POST", "/api/auth/logout", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("POST", "/api/auth/logout-anonymous", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// Токен, выданный /api/auth, подписан ключом, опубликованным в JWKS
+func TestHandler_JWKS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewHandler(&fakeRepo{}, testTokens)
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth", handler.Auth)
+	router.GET("/.well-known/jwks.json", handler.JWKS)
+
+	req, _ := http.NewRequest("POST", "/api/auth", bytes.NewBufferString(`{"username": "alice", "password": "password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var auth map[string]string
+	err := json.Unmarshal(w.Body.Bytes(), &auth)
+	assert.NoError(t, err)
+
+	header, err := base64.RawURLEncoding.DecodeString(strings.Split(auth["token"], ".")[0])
+	assert.NoError(t, err)
+	var tokenHeader map[string]string
+	err = json.Unmarshal(header, &tokenHeader)
+	assert.NoError(t, err)
+
+	req, _ = http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var jwks struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &jwks)
+	assert.NoError(t, err)
+	if assert.Len(t, jwks.Keys, 1) {
+		assert.Equal(t, tokenHeader["kid"], jwks.Keys[0]["kid"])
+		assert.Equal(t, tokenHeader["alg"], jwks.Keys[0]["alg"])
+		assert.Empty(t, jwks.Keys[0]["d"], "закрытая часть ключа не публикуется")
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"merch-store/internal/apierror"
+	"merch-store/internal/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetCart(c *gin.Context) {
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
+		return
+	}
+	userID := current.ID
+
+	h.respondWithCart(c, userID)
+}
+
+func (h *Handler) AddCartItem(c *gin.Context) {
+	type AddCartItemRequest struct {
+		Item     string `json:"item" binding:"required"`
+		Quantity int    `json:"quantity" binding:"gt=0,lte=100"`
+	}
+	req := AddCartItemRequest{Quantity: 1}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, apierror.Validation(err))
+		return
+	}
+
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
+		return
+	}
+	userID := current.ID
+
+	if err := h.repo.AddToCart(c.Request.Context(), userID, req.Item, req.Quantity); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	h.respondWithCart(c, userID)
+}
+
+func (h *Handler) RemoveCartItem(c *gin.Context) {
+	item := c.Param("item")
+	if item == "" {
+		middleware.AbortWithError(c, apierror.InvalidRequest("item is required"))
+		return
+	}
+
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
+		return
+	}
+	userID := current.ID
+
+	if err := h.repo.RemoveFromCart(c.Request.Context(), userID, item); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	h.respondWithCart(c, userID)
+}
+
+func (h *Handler) Checkout(c *gin.Context) {
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
+		return
+	}
+	userID := current.ID
+
+	purchases, balance, err := h.repo.Checkout(c.Request.Context(), userID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	charged := 0
+	items := []map[string]interface{}{}
+	for _, p := range purchases {
+		charged += p.Total()
+		items = append(items, map[string]interface{}{
+			"item":     p.MerchName,
+			"quantity": p.Quantity,
+			"charged":  p.Total(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "checkout successful",
+		"purchases": items,
+		"charged":   charged,
+		"balance":   balance,
+	})
+}
+
+func (h *Handler) respondWithCart(c *gin.Context, userID int) {
+	cart, err := h.repo.GetCart(c.Request.Context(), userID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	total := 0
+	items := []map[string]interface{}{}
+	for _, item := range cart {
+		total += item.Subtotal()
+		items = append(items, map[string]interface{}{
+			"item":      item.MerchName,
+			"title":     item.Title,
+			"price":     item.Price,
+			"quantity":  item.Quantity,
+			"subtotal":  item.Subtotal(),
+			"available": item.Available,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+		"total": total,
+	})
+}
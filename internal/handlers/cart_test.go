@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/middleware"
+)
+
+func newCartRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewHandler(&fakeRepo{}, testTokens)
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		middleware.SetCurrentEmployee(c, middleware.AuthenticatedEmployee{ID: 1})
+		c.Next()
+	})
+	router.GET("/api/cart", handler.GetCart)
+	router.POST("/api/cart/items", handler.AddCartItem)
+	router.DELETE("/api/cart/items/:item", handler.RemoveCartItem)
+	router.POST("/api/cart/checkout", handler.Checkout)
+	return router
+}
+
+func TestHandler_GetCart(t *testing.T) {
+	router := newCartRouter()
+
+	req, _ := http.NewRequest("GET", "/api/cart", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(20), resp["total"])
+	assert.Len(t, resp["items"], 1)
+}
+
+func TestHandler_AddCartItem(t *testing.T) {
+	router := newCartRouter()
+
+	cases := []struct {
+		payload string
+		code    int
+	}{
+		{`{"item": "pen", "quantity": 2}`, http.StatusOK},
+		{`{"item": "pen"}`, http.StatusOK},
+		{`{"item": "pen", "quantity": 0}`, http.StatusBadRequest},
+		{`{"quantity": 1}`, http.StatusBadRequest},
+		{`{"item": "yacht"}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("POST", "/api/cart/items", bytes.NewBufferString(tc.payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, "запрос %s", tc.payload)
+	}
+}
+
+func TestHandler_RemoveCartItem(t *testing.T) {
+	router := newCartRouter()
+
+	req, _ := http.NewRequest("DELETE", "/api/cart/items/pen", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Товара нет в корзине
+	req, _ = http.NewRequest("DELETE", "/api/cart/items/cup", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "not_found", resp["code"])
+}
+
+func TestHandler_Checkout(t *testing.T) {
+	router := newCartRouter()
+
+	req, _ := http.NewRequest("POST", "/api/cart/checkout", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "checkout successful", resp["message"])
+	assert.Equal(t, float64(20), resp["charged"])
+	assert.Equal(t, float64(980), resp["balance"])
+}
@@ -1,22 +1,36 @@
 package handlers
 
 import (
+	"errors"
+	"io"
+	"merch-store/internal/apierror"
 	"merch-store/internal/middleware"
 	"merch-store/internal/repository"
 	"net/http"
+	"sort"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// maxPasswordBytes is the bcrypt input limit. It counts bytes, not
+// characters, so a password in Cyrillic reaches it at 36 letters.
+const maxPasswordBytes = 72
+
 type Handler struct {
-	repo      repository.Repository
-	jwtSecret string
+	repo   repository.Repository
+	tokens *middleware.Tokens
+	oidc   OIDCProvider
+	// oidcSecure marks the OIDC flow cookie Secure. It follows the scheme of
+	// the redirect URL rather than the request, which is plain HTTP behind a
+	// TLS-terminating proxy.
+	oidcSecure bool
 }
 
-func NewHandler(repo repository.Repository, jwtSecret string) *Handler {
+func NewHandler(repo repository.Repository, tokens *middleware.Tokens) *Handler {
 	return &Handler{
-		repo:      repo,
-		jwtSecret: jwtSecret,
+		repo:   repo,
+		tokens: tokens,
 	}
 }
 
@@ -27,46 +41,170 @@ func (h *Handler) Auth(c *gin.Context) {
 	}
 	var req AuthRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		middleware.AbortWithError(c, apierror.Validation(err))
+		return
+	}
+	if len(req.Password) > maxPasswordBytes {
+		middleware.AbortWithError(c, apierror.InvalidRequest("invalid request").
+			WithDetails(map[string]interface{}{"fields": map[string]string{"password": "max"}}))
 		return
 	}
 
-	employee, err := h.repo.GetEmployeeByUsername(req.Username)
-	if err != nil {
-		employee, err = h.repo.CreateEmployee(req.Username)
+	// Unknown employees are registered on their first login. Any other
+	// failure must not be mistaken for that.
+	employee, err := h.repo.GetEmployeeByUsername(c.Request.Context(), req.Username)
+	if errors.Is(err, repository.ErrNotFound) {
+		var hash []byte
+		hash, err = bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"errors": "cannot create employee"})
+			middleware.AbortWithError(c, apierror.Internal("cannot hash password").Wrap(err))
+			return
+		}
+		employee, err = h.repo.CreateEmployee(c.Request.Context(), req.Username, string(hash))
+		switch {
+		case errors.Is(err, repository.ErrAlreadyExists):
+			// A simultaneous first login registered the employee. Its
+			// password is checked like on any later login.
+			employee, err = h.repo.GetEmployeeByUsername(c.Request.Context(), req.Username)
+		case err != nil:
+			middleware.AbortWithError(c, apierror.Internal("cannot create employee").Wrap(err))
+			return
+		default:
+			h.startSession(c, employee)
 			return
 		}
 	}
 
-	token, err := middleware.GenerateJWT(employee.ID, h.jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"errors": "cannot generate token"})
+	switch {
+	case err != nil:
+		middleware.AbortWithError(c, err)
+		return
+	case employee.PasswordHash == "":
+		// Employees registered before passwords existed set theirs on the
+		// next login, just as anyone could log in as them before. SSO-only
+		// accounts have no password either and are refused.
+		var hash []byte
+		hash, err = bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			middleware.AbortWithError(c, apierror.Internal("cannot hash password").Wrap(err))
+			return
+		}
+		set, err := h.repo.SetInitialPassword(c.Request.Context(), employee.ID, string(hash))
+		if err != nil {
+			middleware.AbortWithError(c, err)
+			return
+		}
+		if !set {
+			middleware.AbortWithError(c, apierror.Unauthorized("invalid username or password"))
+			return
+		}
+	case bcrypt.CompareHashAndPassword([]byte(employee.PasswordHash), []byte(req.Password)) != nil:
+		middleware.AbortWithError(c, apierror.Unauthorized("invalid username or password"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	h.startSession(c, employee)
 }
 
 func (h *Handler) BuyItem(c *gin.Context) {
+	type BuyRequest struct {
+		Quantity int `json:"quantity" binding:"gt=0,lte=100"`
+	}
 	item := c.Param("item")
 	if item == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"errors": "item is required"})
+		middleware.AbortWithError(c, apierror.InvalidRequest("item is required"))
 		return
 	}
-	userIDInterface, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"errors": "user not found in context"})
+	req := BuyRequest{Quantity: 1}
+	if c.Request.Method == http.MethodPost {
+		// The body is optional: a POST without one buys a single item,
+		// like the GET form.
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			middleware.AbortWithError(c, apierror.Validation(err))
+			return
+		}
+	}
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
 		return
 	}
-	userID := int(userIDInterface.(float64))
+	userID := current.ID
 
-	if err := h.repo.BuyMerch(userID, item, 1); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+	purchase, balance, err := h.repo.BuyMerch(c.Request.Context(), userID, item, req.Quantity)
+	if err != nil {
+		middleware.AbortWithError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "purchase successful"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "purchase successful",
+		"item":     purchase.MerchName,
+		"quantity": purchase.Quantity,
+		"charged":  purchase.Total(),
+		"balance":  balance,
+	})
+}
+
+func (h *Handler) ListMerch(c *gin.Context) {
+	type ListMerchQuery struct {
+		MaxPrice   int    `form:"maxPrice" binding:"omitempty,gt=0"`
+		Sort       string `form:"sort" binding:"omitempty,oneof=price name"`
+		Affordable bool   `form:"affordable"`
+	}
+	var query ListMerchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		middleware.AbortWithError(c, apierror.Validation(err))
+		return
+	}
+
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
+		return
+	}
+	userID := current.ID
+
+	employee, err := h.repo.GetEmployeeByID(c.Request.Context(), userID)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	items, err := h.repo.ListMerchItems(c.Request.Context())
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	if query.Sort == "price" {
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Price < items[j].Price })
+	} else {
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	}
+
+	catalog := []map[string]interface{}{}
+	for _, item := range items {
+		if query.MaxPrice > 0 && item.Price > query.MaxPrice {
+			continue
+		}
+		// Items taken off sale stay listed as unavailable, but cannot be
+		// bought, so they are never affordable.
+		affordable := item.Active && item.Price <= employee.CoinBalance
+		if query.Affordable && !affordable {
+			continue
+		}
+		catalog = append(catalog, map[string]interface{}{
+			"name":        item.Name,
+			"title":       item.Title,
+			"description": item.Description,
+			"price":       item.Price,
+			"available":   item.Active,
+			"affordable":  affordable,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"coins": employee.CoinBalance,
+		"items": catalog,
+	})
 }
 
 func (h *Handler) SendCoin(c *gin.Context) {
@@ -76,25 +214,28 @@ func (h *Handler) SendCoin(c *gin.Context) {
 	}
 	var req SendCoinRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		middleware.AbortWithError(c, apierror.Validation(err))
 		return
 	}
 
-	userIDInterface, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"errors": "user not found in context"})
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
 		return
 	}
-	fromUserID := int(userIDInterface.(float64))
+	fromUserID := current.ID
 
-	recipient, err := h.repo.GetEmployeeByUsername(req.ToUser)
+	recipient, err := h.repo.GetEmployeeByUsername(c.Request.Context(), req.ToUser)
+	if errors.Is(err, repository.ErrNotFound) {
+		middleware.AbortWithError(c, apierror.New(http.StatusBadRequest, apierror.CodeRecipientNotFound, "recipient not found"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"errors": "recipient not found"})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
-	if err := h.repo.TransferCoins(fromUserID, recipient.ID, req.Amount); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+	if err := h.repo.TransferCoins(c.Request.Context(), fromUserID, recipient.ID, req.Amount); err != nil {
+		middleware.AbortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "transfer successful"})
@@ -102,16 +243,15 @@ func (h *Handler) SendCoin(c *gin.Context) {
 
 func (h *Handler) GetInfo(c *gin.Context) {
 
-	userIDInterface, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"errors": "user not found in context"})
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
 		return
 	}
-	userID := int(userIDInterface.(float64))
+	userID := current.ID
 
-	balance, transactions, err := h.repo.GetWalletInfo(userID)
+	balance, transactions, err := h.repo.GetWalletInfo(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
@@ -120,20 +260,20 @@ func (h *Handler) GetInfo(c *gin.Context) {
 	for _, t := range transactions {
 		if t.Amount > 0 {
 			received = append(received, map[string]interface{}{
-				"fromUser": t.CounterpartyID,
+				"fromUser": t.CounterpartyUsername,
 				"amount":   t.Amount,
 			})
 		} else {
 			sent = append(sent, map[string]interface{}{
-				"toUser": t.CounterpartyID,
+				"toUser": t.CounterpartyUsername,
 				"amount": -t.Amount,
 			})
 		}
 	}
 
-	inventory, err := h.repo.GetInventory(userID)
+	inventory, err := h.repo.GetInventory(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
@@ -2,39 +2,76 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"merch-store/internal/middleware"
 	"merch-store/internal/models"
+	"merch-store/internal/repository"
 )
 
+// testTokens выдаёт и проверяет токены в тестах
+var testTokens = newTestTokens()
+
+func newTestTokens() *middleware.Tokens {
+	keys, err := middleware.GenerateKeySet()
+	if err != nil {
+		panic(err)
+	}
+	return middleware.NewTokens(keys, "merch-store", "merch-store")
+}
+
+// fakePasswordHash – хэш пароля "password123", с которым fakeRepo возвращает сотрудников
+var fakePasswordHash, _ = bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+
 // fakeRepo – минимальная реализация интерфейса repository.Repository для unit тестов
 type fakeRepo struct{}
 
-func (f *fakeRepo) CreateEmployee(username string) (models.Employee, error) {
+func (f *fakeRepo) CreateEmployee(ctx context.Context, username, passwordHash string) (models.Employee, error) {
 	return models.Employee{
-		ID:          1,
-		Username:    username,
-		CoinBalance: 1000,
-		CreatedAt:   time.Now(),
+		ID:           1,
+		Username:     username,
+		PasswordHash: passwordHash,
+		Role:         models.RoleEmployee,
+		CoinBalance:  1000,
+		CreatedAt:    time.Now(),
 	}, nil
 }
 
-func (f *fakeRepo) GetEmployeeByUsername(username string) (models.Employee, error) {
+func (f *fakeRepo) GetEmployeeByUsername(ctx context.Context, username string) (models.Employee, error) {
 	return models.Employee{
-		ID:          1,
-		Username:    username,
-		CoinBalance: 1000,
-		CreatedAt:   time.Now(),
+		ID:           1,
+		Username:     username,
+		PasswordHash: string(fakePasswordHash),
+		Role:         models.RoleEmployee,
+		CoinBalance:  1000,
+		CreatedAt:    time.Now(),
 	}, nil
 }
 
-func (f *fakeRepo) GetEmployeeByID(id int) (models.Employee, error) {
+func (f *fakeRepo) GetEmployeeByIdentity(ctx context.Context, issuer, subject string) (models.Employee, error) {
+	return models.Employee{}, repository.ErrNotFound
+}
+
+func (f *fakeRepo) LinkIdentity(ctx context.Context, employeeID int, issuer, subject, email string) error {
+	return nil
+}
+
+func (f *fakeRepo) SetInitialPassword(ctx context.Context, employeeID int, passwordHash string) (bool, error) {
+	return true, nil
+}
+
+func (f *fakeRepo) GetEmployeeByID(ctx context.Context, id int) (models.Employee, error) {
 	return models.Employee{
 		ID:          id,
 		Username:    "test",
@@ -43,28 +80,202 @@ func (f *fakeRepo) GetEmployeeByID(id int) (models.Employee, error) {
 	}, nil
 }
 
-func (f *fakeRepo) BuyMerch(employeeID int, merchName string, quantity int) error {
+func (f *fakeRepo) CreateSession(ctx context.Context, employeeID int, sessionID, refreshTokenHash string, expiresAt time.Time) error {
+	return nil
+}
+
+// RotateRefreshToken принимает только refresh токен "valid"; токен "reused" считается повторно использованным
+func (f *fakeRepo) RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string, expiresAt time.Time) (models.Session, error) {
+	switch refreshTokenHash {
+	case hashToken("valid"):
+		return models.Session{ID: "session-1", EmployeeID: 1}, nil
+	case hashToken("reused"):
+		return models.Session{}, repository.ErrTokenReused
+	}
+	return models.Session{}, repository.ErrNotFound
+}
+
+func (f *fakeRepo) RevokeSession(ctx context.Context, sessionID string) error {
+	return nil
+}
+
+func (f *fakeRepo) SessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeRepo) SetEmployeeRole(ctx context.Context, actorID, employeeID int, role string) error {
+	if !models.ValidRole(role) {
+		return repository.ErrInvalidRole
+	}
+	return nil
+}
+
+func (f *fakeRepo) ListRoleChanges(ctx context.Context, employeeID int) ([]models.RoleChange, error) {
+	return []models.RoleChange{
+		{ID: 1, EmployeeID: employeeID, OldRole: models.RoleEmployee, NewRole: models.RoleStoreManager, ChangedBy: 2, ChangedByUsername: "boss", CreatedAt: time.Now()},
+	}, nil
+}
+
+func (f *fakeRepo) ListMerchItems(ctx context.Context) ([]models.MerchItem, error) {
+	return []models.MerchItem{
+		{Name: "pen", Title: "Pen", Price: 10, Active: true},
+		{Name: "t-shirt", Title: "T-shirt", Price: 80, Active: true},
+		{Name: "golden-hoody", Title: "Golden hoody", Price: 5000, Active: true},
+		{Name: "mug", Title: "Mug", Price: 15, Active: false},
+	}, nil
+}
+
+func (f *fakeRepo) GetMerchItem(ctx context.Context, name string) (models.MerchItem, error) {
+	items, _ := f.ListMerchItems(ctx)
+	for _, item := range items {
+		if item.Name == name {
+			return item, nil
+		}
+	}
+	return models.MerchItem{}, repository.ErrInvalidMerch
+}
+
+// merchItem ищет товар, доступный для покупки
+func (f *fakeRepo) merchItem(ctx context.Context, name string) (models.MerchItem, error) {
+	item, err := f.GetMerchItem(ctx, name)
+	if err != nil {
+		return item, err
+	}
+	if !item.Active {
+		return models.MerchItem{}, repository.ErrInvalidMerch
+	}
+	return item, nil
+}
+
+func (f *fakeRepo) BuyMerch(ctx context.Context, employeeID int, merchName string, quantity int) (models.Purchase, int, error) {
+	item, err := f.merchItem(ctx, merchName)
+	if err != nil {
+		return models.Purchase{}, 0, err
+	}
+	purchase := models.Purchase{
+		ID:         1,
+		EmployeeID: employeeID,
+		MerchName:  merchName,
+		Price:      item.Price,
+		Quantity:   quantity,
+		CreatedAt:  time.Now(),
+	}
+	return purchase, 1000 - purchase.Total(), nil
+}
+
+func (f *fakeRepo) AddToCart(ctx context.Context, employeeID int, merchName string, quantity int) error {
+	_, err := f.merchItem(ctx, merchName)
+	return err
+}
+
+func (f *fakeRepo) RemoveFromCart(ctx context.Context, employeeID int, merchName string) error {
+	if merchName != "pen" {
+		return repository.ErrNotFound
+	}
 	return nil
 }
 
-func (f *fakeRepo) TransferCoins(fromID, toID, amount int) error {
+func (f *fakeRepo) GetCart(ctx context.Context, employeeID int) ([]models.CartItem, error) {
+	return []models.CartItem{{MerchName: "pen", Title: "Pen", Price: 10, Quantity: 2, Available: true}}, nil
+}
+
+func (f *fakeRepo) Checkout(ctx context.Context, employeeID int) ([]models.Purchase, int, error) {
+	return []models.Purchase{{ID: 1, EmployeeID: employeeID, MerchName: "pen", Price: 10, Quantity: 2}}, 980, nil
+}
+
+func (f *fakeRepo) TransferCoins(ctx context.Context, fromID, toID, amount int) error {
 	return nil
 }
 
-func (f *fakeRepo) GetWalletInfo(employeeID int) (int, []models.Transaction, error) {
-	return 1000, []models.Transaction{}, nil
+// GrantCoins отказывает в списании больше баланса по умолчанию (1000)
+func (f *fakeRepo) GrantCoins(ctx context.Context, actorID int, grants []models.Grant) ([]models.Transaction, error) {
+	transactions := make([]models.Transaction, 0, len(grants))
+	for i, g := range grants {
+		if g.Amount < -1000 {
+			return nil, repository.ErrInsufficientFunds
+		}
+		txType := repository.TransactionTypeGrant
+		if g.Amount < 0 {
+			txType = repository.TransactionTypeClawback
+		}
+		transactions = append(transactions, models.Transaction{
+			ID:              i + 1,
+			EmployeeID:      g.EmployeeID,
+			CounterpartyID:  actorID,
+			Amount:          g.Amount,
+			TransactionType: txType,
+			Reason:          g.Reason,
+		})
+	}
+	return transactions, nil
 }
 
-func (f *fakeRepo) GetInventory(employeeID int) ([]map[string]interface{}, error) {
+func (f *fakeRepo) ReleaseDueGrants(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+func (f *fakeRepo) GetWalletInfo(ctx context.Context, employeeID int) (int, []models.Transaction, error) {
+	return 1000, []models.Transaction{
+		{ID: 1, EmployeeID: employeeID, CounterpartyID: 2, CounterpartyUsername: "alice", Amount: 50, TransactionType: "transfer"},
+		{ID: 2, EmployeeID: employeeID, CounterpartyID: 3, CounterpartyUsername: "bob", Amount: -30, TransactionType: "transfer"},
+	}, nil
+}
+
+// ListTransactions отдаёт пять синтетических транзакций, новые первыми
+func (f *fakeRepo) ListTransactions(ctx context.Context, employeeID int, filter models.TransactionFilter) ([]models.Transaction, error) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	transactions := []models.Transaction{}
+	for id := 5; id >= 1; id-- {
+		t := models.Transaction{
+			ID:                   id,
+			EmployeeID:           employeeID,
+			CounterpartyUsername: "alice",
+			Amount:               id * 10,
+			TransactionType:      "transfer",
+			CreatedAt:            base.Add(time.Duration(id) * time.Hour),
+		}
+		if filter.After != nil && !t.CreatedAt.Before(filter.After.CreatedAt) {
+			continue
+		}
+		if len(transactions) == filter.Limit {
+			break
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, nil
+}
+
+func (f *fakeRepo) GetInventory(ctx context.Context, employeeID int) ([]map[string]interface{}, error) {
 	return []map[string]interface{}{}, nil
 }
 
+func (f *fakeRepo) VerifyBalance(ctx context.Context, employeeID int) error {
+	return nil
+}
+
+func (f *fakeRepo) ReserveIdempotencyKey(ctx context.Context, employeeID int, key, fingerprint string, retention time.Duration) (models.IdempotencyRecord, bool, error) {
+	return models.IdempotencyRecord{Key: key, Fingerprint: fingerprint}, true, nil
+}
+
+func (f *fakeRepo) CompleteIdempotencyKey(ctx context.Context, employeeID int, key string, statusCode int, responseBody []byte) error {
+	return nil
+}
+
+func (f *fakeRepo) ReleaseIdempotencyKey(ctx context.Context, employeeID int, key string) error {
+	return nil
+}
+
+func (f *fakeRepo) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	return 0, nil
+}
+
 func TestHandler_Auth(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	repo := &fakeRepo{}
-	handler := NewHandler(repo, "test_secret")
+	handler := NewHandler(repo, testTokens)
 
 	router := gin.New()
+	router.Use(middleware.ErrorHandler())
 	router.POST("/api/auth", handler.Auth)
 
 	payload := map[string]string{
@@ -87,14 +298,175 @@ func TestHandler_Auth(t *testing.T) {
 	assert.True(t, exists, "token должен присутствовать в ответе")
 }
 
+func TestHandler_Auth_WrongPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &fakeRepo{}
+	handler := NewHandler(repo, testTokens)
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth", handler.Auth)
+
+	payload := map[string]string{
+		"username": "testuser",
+		"password": "wrong-password",
+	}
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/api/auth", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	var resp map[string]string
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	_, exists := resp["token"]
+	assert.False(t, exists, "token не должен выдаваться при неверном пароле")
+}
+
+// failingRepo возвращает заданную ошибку при поиске сотрудника и покупке
+type failingRepo struct {
+	fakeRepo
+	err     error
+	created bool
+}
+
+func (f *failingRepo) GetEmployeeByUsername(ctx context.Context, username string) (models.Employee, error) {
+	return models.Employee{}, f.err
+}
+
+func (f *failingRepo) CreateEmployee(ctx context.Context, username, passwordHash string) (models.Employee, error) {
+	f.created = true
+	return f.fakeRepo.CreateEmployee(ctx, username, passwordHash)
+}
+
+func (f *failingRepo) BuyMerch(ctx context.Context, employeeID int, merchName string, quantity int) (models.Purchase, int, error) {
+	return models.Purchase{}, 0, f.err
+}
+
+func newFailingRouter(repo *failingRepo) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewHandler(repo, testTokens)
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth", handler.Auth)
+	router.Use(func(c *gin.Context) {
+		middleware.SetCurrentEmployee(c, middleware.AuthenticatedEmployee{ID: 1})
+		c.Next()
+	})
+	router.GET("/api/buy/:item", handler.BuyItem)
+	router.POST("/api/sendCoin", handler.SendCoin)
+	return router
+}
+
+func doJSON(router *gin.Engine, method, path, payload string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler_Auth_RegistersOnlyUnknownEmployees(t *testing.T) {
+	repo := &failingRepo{err: repository.ErrNotFound}
+	w := doJSON(newFailingRouter(repo), "POST", "/api/auth", `{"username": "newbie", "password": "password123"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, repo.created, "неизвестный сотрудник регистрируется при первом входе")
+
+	repo = &failingRepo{err: driver.ErrBadConn}
+	w = doJSON(newFailingRouter(repo), "POST", "/api/auth", `{"username": "newbie", "password": "password123"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.False(t, repo.created, "при недоступной базе сотрудник не должен создаваться")
+}
+
+// racingRepo имитирует одновременный первый вход: сотрудника еще нет при
+// поиске, но его успевает создать другой запрос
+type racingRepo struct {
+	fakeRepo
+	created bool
+}
+
+func (r *racingRepo) GetEmployeeByUsername(ctx context.Context, username string) (models.Employee, error) {
+	if !r.created {
+		return models.Employee{}, repository.ErrNotFound
+	}
+	return r.fakeRepo.GetEmployeeByUsername(ctx, username)
+}
+
+func (r *racingRepo) CreateEmployee(ctx context.Context, username, passwordHash string) (models.Employee, error) {
+	r.created = true
+	return models.Employee{}, repository.ErrAlreadyExists
+}
+
+func TestHandler_Auth_ConcurrentFirstLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func() *gin.Engine {
+		router := gin.New()
+		router.Use(middleware.ErrorHandler())
+		router.POST("/api/auth", NewHandler(&racingRepo{}, testTokens).Auth)
+		return router
+	}
+
+	w := doJSON(newRouter(), "POST", "/api/auth", `{"username": "newbie", "password": "password123"}`)
+	assert.Equal(t, http.StatusOK, w.Code, "проигравший гонку входит в уже созданную учетную запись")
+
+	w = doJSON(newRouter(), "POST", "/api/auth", `{"username": "newbie", "password": "wrong"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "пароль проверяется по созданной учетной записи")
+}
+
+// Ограничение bcrypt считается в байтах: 40 кириллических букв – это 80 байт
+func TestHandler_Auth_PasswordTooLong(t *testing.T) {
+	router := newFailingRouter(&failingRepo{err: repository.ErrNotFound})
+
+	body, _ := json.Marshal(map[string]string{"username": "newbie", "password": strings.Repeat("п", 40)})
+	w := doJSON(router, "POST", "/api/auth", string(body))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid_request", resp["code"])
+	assert.Equal(t, map[string]interface{}{"fields": map[string]interface{}{"password": "max"}}, resp["details"])
+
+	body, _ = json.Marshal(map[string]string{"username": "newbie", "password": strings.Repeat("п", 36)})
+	w = doJSON(router, "POST", "/api/auth", string(body))
+	assert.Equal(t, http.StatusOK, w.Code, "72 байта ещё допустимы")
+}
+
+// Сбой базы данных – это не ошибка клиента
+func TestHandler_DatabaseOutage(t *testing.T) {
+	router := newFailingRouter(&failingRepo{err: driver.ErrBadConn})
+
+	assert.Equal(t, http.StatusServiceUnavailable, doJSON(router, "GET", "/api/buy/t-shirt", "").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, doJSON(router, "POST", "/api/sendCoin", `{"toUser": "bob", "amount": 10}`).Code)
+
+	router = newFailingRouter(&failingRepo{err: errors.New("pq: relation \"purchases\" does not exist")})
+	w := doJSON(router, "GET", "/api/buy/t-shirt", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "pq:")
+}
+
+func TestHandler_SendCoin_UnknownRecipient(t *testing.T) {
+	router := newFailingRouter(&failingRepo{err: repository.ErrNotFound})
+
+	w := doJSON(router, "POST", "/api/sendCoin", `{"toUser": "ghost", "amount": 10}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "recipient_not_found", resp["code"])
+}
+
 func TestHandler_BuyItem(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	repo := &fakeRepo{}
-	handler := NewHandler(repo, "test_secret")
+	handler := NewHandler(repo, testTokens)
 
 	router := gin.New()
+	router.Use(middleware.ErrorHandler())
 	router.Use(func(c *gin.Context) {
-		c.Set("userID", float64(1))
+		middleware.SetCurrentEmployee(c, middleware.AuthenticatedEmployee{ID: 1})
 		c.Next()
 	})
 	router.GET("/api/buy/:item", handler.BuyItem)
@@ -104,20 +476,65 @@ func TestHandler_BuyItem(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	var resp map[string]string
+	var resp map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &resp)
 	assert.NoError(t, err)
 	assert.Equal(t, "purchase successful", resp["message"])
 }
 
+func TestHandler_BuyItem_Quantity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &fakeRepo{}
+	handler := NewHandler(repo, testTokens)
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		middleware.SetCurrentEmployee(c, middleware.AuthenticatedEmployee{ID: 1})
+		c.Next()
+	})
+	router.POST("/api/buy/:item", handler.BuyItem)
+
+	buy := func(payload string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", "/api/buy/pen", bytes.NewBufferString(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	code, resp := buy(`{"quantity": 10}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(10), resp["quantity"])
+	assert.Equal(t, float64(100), resp["charged"])
+	assert.Equal(t, float64(900), resp["balance"])
+
+	code, resp = buy(`{}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(1), resp["quantity"], "по умолчанию покупается один предмет")
+
+	code, resp = buy("")
+	assert.Equal(t, http.StatusOK, code, "тело запроса необязательно")
+	assert.Equal(t, float64(1), resp["quantity"])
+
+	for _, payload := range []string{`{"quantity": 0}`, `{"quantity": -1}`, `{"quantity": 101}`, `not json`} {
+		code, _ = buy(payload)
+		assert.Equal(t, http.StatusBadRequest, code, "запрос %s должен отклоняться", payload)
+	}
+}
+
 func TestHandler_SendCoin(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	repo := &fakeRepo{}
-	handler := NewHandler(repo, "test_secret")
+	handler := NewHandler(repo, testTokens)
 
 	router := gin.New()
+	router.Use(middleware.ErrorHandler())
 	router.Use(func(c *gin.Context) {
-		c.Set("userID", float64(1))
+		middleware.SetCurrentEmployee(c, middleware.AuthenticatedEmployee{ID: 1})
 		c.Next()
 	})
 	router.POST("/api/sendCoin", handler.SendCoin)
@@ -143,11 +560,12 @@ func TestHandler_SendCoin(t *testing.T) {
 func TestHandler_GetInfo(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	repo := &fakeRepo{}
-	handler := NewHandler(repo, "test_secret")
+	handler := NewHandler(repo, testTokens)
 
 	router := gin.New()
+	router.Use(middleware.ErrorHandler())
 	router.Use(func(c *gin.Context) {
-		c.Set("userID", float64(1))
+		middleware.SetCurrentEmployee(c, middleware.AuthenticatedEmployee{ID: 1})
 		c.Next()
 	})
 	router.GET("/api/info", handler.GetInfo)
@@ -167,4 +585,81 @@ func TestHandler_GetInfo(t *testing.T) {
 	assert.True(t, ok, "inventory должен присутствовать в ответе")
 	_, ok = resp["coinHistory"]
 	assert.True(t, ok, "coinHistory должен присутствовать в ответе")
+
+	var info struct {
+		CoinHistory struct {
+			Received []struct {
+				FromUser string `json:"fromUser"`
+				Amount   int    `json:"amount"`
+			} `json:"received"`
+			Sent []struct {
+				ToUser string `json:"toUser"`
+				Amount int    `json:"amount"`
+			} `json:"sent"`
+		} `json:"coinHistory"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &info)
+	assert.NoError(t, err)
+	if assert.Len(t, info.CoinHistory.Received, 1) {
+		assert.Equal(t, "alice", info.CoinHistory.Received[0].FromUser)
+		assert.Equal(t, 50, info.CoinHistory.Received[0].Amount)
+	}
+	if assert.Len(t, info.CoinHistory.Sent, 1) {
+		assert.Equal(t, "bob", info.CoinHistory.Sent[0].ToUser)
+		assert.Equal(t, 30, info.CoinHistory.Sent[0].Amount)
+	}
+}
+
+func TestHandler_ListMerch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &fakeRepo{}
+	handler := NewHandler(repo, testTokens)
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		middleware.SetCurrentEmployee(c, middleware.AuthenticatedEmployee{ID: 1})
+		c.Next()
+	})
+	router.GET("/api/merch", handler.ListMerch)
+
+	list := func(query string) (int, []string) {
+		req, _ := http.NewRequest("GET", "/api/merch"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp struct {
+			Items []struct {
+				Name       string `json:"name"`
+				Available  bool   `json:"available"`
+				Affordable bool   `json:"affordable"`
+			} `json:"items"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		names := []string{}
+		for _, item := range resp.Items {
+			names = append(names, item.Name)
+			if item.Name == "mug" {
+				assert.False(t, item.Available, "снятый с продажи товар показывается недоступным")
+				assert.False(t, item.Affordable, "снятый с продажи товар нельзя купить даже при достаточном балансе")
+			}
+		}
+		return w.Code, names
+	}
+
+	code, names := list("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"golden-hoody", "mug", "pen", "t-shirt"}, names)
+
+	_, names = list("?sort=price")
+	assert.Equal(t, []string{"pen", "mug", "t-shirt", "golden-hoody"}, names)
+
+	_, names = list("?maxPrice=50")
+	assert.Equal(t, []string{"mug", "pen"}, names)
+
+	_, names = list("?affordable=true&sort=price")
+	assert.Equal(t, []string{"pen", "t-shirt"}, names, "товары дороже баланса и снятые с продажи должны отфильтровываться")
+
+	code, _ = list("?sort=color")
+	assert.Equal(t, http.StatusBadRequest, code)
 }
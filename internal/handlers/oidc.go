@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"merch-store/internal/apierror"
+	"merch-store/internal/middleware"
+	"merch-store/internal/repository"
+	"merch-store/internal/sso"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	oidcCookiePath = "/api/auth/oidc"
+	// oidcFlowMaxAge is how long the user has to log in at the identity
+	// provider.
+	oidcFlowMaxAge = 600
+)
+
+// OIDCProvider is implemented by *sso.Provider.
+type OIDCProvider interface {
+	AuthCodeURL(state, nonce, verifier string) string
+	Exchange(ctx context.Context, code, verifier, nonce string) (sso.Identity, error)
+}
+
+// SetOIDCProvider enables login through an OpenID Connect identity provider.
+// redirectURL is the callback registered at the provider.
+func (h *Handler) SetOIDCProvider(provider OIDCProvider, redirectURL string) {
+	h.oidc = provider
+	u, err := url.Parse(redirectURL)
+	h.oidcSecure = err == nil && u.Scheme == "https"
+}
+
+// OIDCLogin redirects the browser to the identity provider. The state, nonce
+// and PKCE verifier of the login are kept in a short-lived cookie and checked
+// by OIDCCallback.
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	if h.oidc == nil {
+		middleware.AbortWithError(c, apierror.NotFound("OIDC login is not configured"))
+		return
+	}
+
+	state, err := randomToken(16)
+	if err != nil {
+		middleware.AbortWithError(c, apierror.Internal("cannot start login").Wrap(err))
+		return
+	}
+	nonce, err := randomToken(16)
+	if err != nil {
+		middleware.AbortWithError(c, apierror.Internal("cannot start login").Wrap(err))
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, state+"."+nonce+"."+verifier, oidcFlowMaxAge, oidcCookiePath, "", h.oidcSecure, true)
+	c.Redirect(http.StatusFound, h.oidc.AuthCodeURL(state, nonce, verifier))
+}
+
+// OIDCCallback finishes the login started by OIDCLogin. The identity is
+// mapped to an employee by its subject, or on first login by its verified
+// email; an employee that does not exist yet is created with the welcome
+// bonus. The response is the same as for /api/auth.
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	if h.oidc == nil {
+		middleware.AbortWithError(c, apierror.NotFound("OIDC login is not configured"))
+		return
+	}
+
+	flow, err := c.Cookie(oidcFlowCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, "", -1, oidcCookiePath, "", h.oidcSecure, true)
+	parts := strings.Split(flow, ".")
+	if err != nil || len(parts) != 3 || c.Query("state") != parts[0] {
+		middleware.AbortWithError(c, apierror.InvalidRequest("invalid login state"))
+		return
+	}
+	if c.Query("error") != "" {
+		middleware.AbortWithError(c, apierror.Unauthorized("identity provider rejected login"))
+		return
+	}
+
+	identity, err := h.oidc.Exchange(c.Request.Context(), c.Query("code"), parts[2], parts[1])
+	if err != nil {
+		middleware.AbortWithError(c, apierror.Unauthorized("cannot verify identity"))
+		return
+	}
+
+	employee, err := h.repo.GetEmployeeByIdentity(c.Request.Context(), identity.Issuer, identity.Subject)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	if err != nil {
+		if identity.Email == "" || !identity.EmailVerified {
+			middleware.AbortWithError(c, apierror.Forbidden("identity provider did not supply a verified email"))
+			return
+		}
+		employee, err = h.repo.GetEmployeeByUsername(c.Request.Context(), identity.Email)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			middleware.AbortWithError(c, err)
+			return
+		}
+		if err != nil {
+			// SSO accounts have no password, so they cannot log in
+			// through /api/auth.
+			employee, err = h.repo.CreateEmployee(c.Request.Context(), identity.Email, "")
+			if err != nil {
+				middleware.AbortWithError(c, apierror.Internal("cannot create employee").Wrap(err))
+				return
+			}
+		}
+		if err = h.repo.LinkIdentity(c.Request.Context(), employee.ID, identity.Issuer, identity.Subject, identity.Email); err != nil {
+			middleware.AbortWithError(c, apierror.Internal("cannot link identity").Wrap(err))
+			return
+		}
+	}
+
+	h.startSession(c, employee)
+}
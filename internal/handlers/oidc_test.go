@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"merch-store/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/sso"
+)
+
+// fakeOIDCProvider принимает только код "good" и возвращает заданную личность
+type fakeOIDCProvider struct {
+	identity sso.Identity
+}
+
+func (p *fakeOIDCProvider) AuthCodeURL(state, nonce, verifier string) string {
+	return "https://idp.example.com/authorize?state=" + url.QueryEscape(state)
+}
+
+func (p *fakeOIDCProvider) Exchange(ctx context.Context, code, verifier, nonce string) (sso.Identity, error) {
+	if code != "good" {
+		return sso.Identity{}, errors.New("invalid code")
+	}
+	return p.identity, nil
+}
+
+func newOIDCRouter(identity sso.Identity) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewHandler(&fakeRepo{}, testTokens)
+	handler.SetOIDCProvider(&fakeOIDCProvider{identity: identity}, "https://merch.example.com/api/auth/oidc/callback")
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.GET("/api/auth/oidc/login", handler.OIDCLogin)
+	router.GET("/api/auth/oidc/callback", handler.OIDCCallback)
+	return router
+}
+
+// startOIDCLogin возвращает state и cookie, выданные при начале входа
+func startOIDCLogin(t *testing.T, router *gin.Engine) (string, *http.Cookie) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", "/api/auth/oidc/login", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	assert.NoError(t, err)
+	cookies := w.Result().Cookies()
+	if !assert.Len(t, cookies, 1) {
+		t.FailNow()
+	}
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure, "адрес возврата https, хотя сам запрос пришел по http")
+	return location.Query().Get("state"), cookies[0]
+}
+
+func oidcCallback(router *gin.Engine, query string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/api/auth/oidc/callback?"+query, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler_OIDCCallback(t *testing.T) {
+	router := newOIDCRouter(sso.Identity{Issuer: "https://idp.example.com", Subject: "sub-1", Email: "alice@example.com", EmailVerified: true})
+
+	state, cookie := startOIDCLogin(t, router)
+	w := oidcCallback(router, "code=good&state="+state, cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), "refreshToken"))
+}
+
+// Без cookie или с чужим state вход не завершается: это защита от CSRF
+func TestHandler_OIDCCallback_InvalidState(t *testing.T) {
+	router := newOIDCRouter(sso.Identity{Issuer: "https://idp.example.com", Subject: "sub-1", Email: "alice@example.com", EmailVerified: true})
+
+	state, cookie := startOIDCLogin(t, router)
+	assert.Equal(t, http.StatusBadRequest, oidcCallback(router, "code=good&state="+state, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, oidcCallback(router, "code=good&state=forged", cookie).Code)
+	assert.Equal(t, http.StatusUnauthorized, oidcCallback(router, "code=bad&state="+state, cookie).Code)
+}
+
+func TestHandler_OIDCCallback_UnverifiedEmail(t *testing.T) {
+	router := newOIDCRouter(sso.Identity{Issuer: "https://idp.example.com", Subject: "sub-1", Email: "alice@example.com"})
+
+	state, cookie := startOIDCLogin(t, router)
+	assert.Equal(t, http.StatusForbidden, oidcCallback(router, "code=good&state="+state, cookie).Code)
+}
+
+func TestHandler_OIDCLogin_NotConfigured(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewHandler(&fakeRepo{}, testTokens)
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.GET("/api/auth/oidc/login", handler.OIDCLogin)
+
+	req, _ := http.NewRequest("GET", "/api/auth/oidc/login", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"merch-store/internal/apierror"
+	"merch-store/internal/middleware"
+	"merch-store/internal/models"
+	"merch-store/internal/repository"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultTransactionsLimit = 20
+	maxTransactionsLimit     = 100
+)
+
+var errInvalidCursor = apierror.InvalidRequest("invalid cursor")
+
+func (h *Handler) ListTransactions(c *gin.Context) {
+	type ListTransactionsQuery struct {
+		Direction    string    `form:"direction" binding:"omitempty,oneof=sent received"`
+		Counterparty string    `form:"counterparty"`
+		Type         string    `form:"type"`
+		From         time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+		To           time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+		Limit        int       `form:"limit" binding:"omitempty,gt=0"`
+		Cursor       string    `form:"cursor"`
+	}
+	var query ListTransactionsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		middleware.AbortWithError(c, apierror.Validation(err))
+		return
+	}
+
+	current, ok := middleware.CurrentEmployee(c)
+	if !ok {
+		return
+	}
+	userID := current.ID
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultTransactionsLimit
+	}
+	if limit > maxTransactionsLimit {
+		limit = maxTransactionsLimit
+	}
+
+	filter := models.TransactionFilter{
+		Direction:    query.Direction,
+		Counterparty: query.Counterparty,
+		Type:         query.Type,
+		From:         query.From,
+		To:           query.To,
+		// One extra row tells whether there is a next page.
+		Limit: limit + 1,
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			middleware.AbortWithError(c, err)
+			return
+		}
+		filter.After = &cursor
+	}
+
+	transactions, err := h.repo.ListTransactions(c.Request.Context(), userID, filter)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	var nextCursor interface{}
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+		last := transactions[limit-1]
+		nextCursor = encodeCursor(models.TransactionCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	items := []map[string]interface{}{}
+	for _, t := range transactions {
+		direction := repository.DirectionReceived
+		amount := t.Amount
+		if t.Amount < 0 {
+			direction = repository.DirectionSent
+			amount = -t.Amount
+		}
+		items = append(items, map[string]interface{}{
+			"id":           t.ID,
+			"type":         t.TransactionType,
+			"direction":    direction,
+			"amount":       amount,
+			"counterparty": t.CounterpartyUsername,
+			"transferId":   t.TransferID,
+			"reason":       t.Reason,
+			"createdAt":    t.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": items,
+		"nextCursor":   nextCursor,
+	})
+}
+
+func encodeCursor(cursor models.TransactionCursor) string {
+	raw := fmt.Sprintf("%d:%d", cursor.CreatedAt.UnixNano(), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(value string) (models.TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return models.TransactionCursor{}, errInvalidCursor
+	}
+	var nanos int64
+	var id int
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &nanos, &id); err != nil {
+		return models.TransactionCursor{}, errInvalidCursor
+	}
+	return models.TransactionCursor{CreatedAt: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
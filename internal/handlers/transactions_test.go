@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/middleware"
+	"merch-store/internal/models"
+)
+
+func TestHandler_ListTransactions_Pagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewHandler(&fakeRepo{}, testTokens)
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		middleware.SetCurrentEmployee(c, middleware.AuthenticatedEmployee{ID: 1})
+		c.Next()
+	})
+	router.GET("/api/transactions", handler.ListTransactions)
+
+	type page struct {
+		Transactions []struct {
+			ID        int    `json:"id"`
+			Direction string `json:"direction"`
+		} `json:"transactions"`
+		NextCursor *string `json:"nextCursor"`
+	}
+	fetch := func(query url.Values) (int, page) {
+		req, _ := http.NewRequest("GET", "/api/transactions?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var p page
+		_ = json.Unmarshal(w.Body.Bytes(), &p)
+		return w.Code, p
+	}
+
+	var ids []int
+	query := url.Values{"limit": {"2"}}
+	for pages := 0; pages < 5; pages++ {
+		code, p := fetch(query)
+		assert.Equal(t, http.StatusOK, code)
+		for _, tr := range p.Transactions {
+			ids = append(ids, tr.ID)
+			assert.Equal(t, "received", tr.Direction)
+		}
+		if p.NextCursor == nil {
+			break
+		}
+		query.Set("cursor", *p.NextCursor)
+	}
+	assert.Equal(t, []int{5, 4, 3, 2, 1}, ids, "страницы должны идти подряд без пропусков и повторов")
+
+	code, _ := fetch(url.Values{"cursor": {"not-a-cursor"}})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = fetch(url.Values{"direction": {"sideways"}})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = fetch(url.Values{"from": {"yesterday"}})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := models.TransactionCursor{CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 123456000, time.UTC), ID: 42}
+
+	decoded, err := decodeCursor(encodeCursor(cursor))
+	assert.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, cursor.ID, decoded.ID)
+}
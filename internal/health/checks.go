@@ -0,0 +1,46 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Check is a dependency the server needs to serve requests.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// DatabaseCheck pings the connection pool.
+func DatabaseCheck(db *sql.DB) Check {
+	return Check{
+		Name: "database",
+		Run:  db.PingContext,
+	}
+}
+
+// SchemaVersioner is implemented by *migrations.Migrator.
+type SchemaVersioner interface {
+	Version(ctx context.Context) (int, error)
+	Latest() int
+}
+
+// MigrationsCheck fails while the database schema is older than the
+// migrations built into the binary. A newer schema is fine: during a rolling
+// deploy the old replicas keep serving after the new ones migrated.
+func MigrationsCheck(versioner SchemaVersioner) Check {
+	return Check{
+		Name: "migrations",
+		Run: func(ctx context.Context) error {
+			version, err := versioner.Version(ctx)
+			if err != nil {
+				return err
+			}
+			if version < versioner.Latest() {
+				return fmt.Errorf("schema is at version %d, expected %d", version, versioner.Latest())
+			}
+			return nil
+		},
+	}
+}
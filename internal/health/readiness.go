@@ -0,0 +1,101 @@
+// Package health serves the liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultCheckTimeout bounds a readiness probe, so that a hanging dependency
+// makes the instance unready instead of stalling the probe.
+const DefaultCheckTimeout = 2 * time.Second
+
+const (
+	statusOK       = "ok"
+	statusFailed   = "failed"
+	statusDraining = "draining"
+)
+
+// Liveness serves the liveness probe. It only tells that the process is up
+// and serving HTTP; dependencies are the business of Readiness.
+func Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": statusOK})
+}
+
+// Readiness tells load balancers whether to route requests to this instance:
+// it is ready while all its checks pass. It turns unready for good once the
+// server starts shutting down.
+type Readiness struct {
+	checks   []Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewReadiness(timeout time.Duration, checks ...Check) *Readiness {
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+	return &Readiness{checks: checks, timeout: timeout}
+}
+
+// SetDraining marks the server as shutting down. In-flight and already routed
+// requests are still served.
+func (r *Readiness) SetDraining() {
+	r.draining.Store(true)
+}
+
+func (r *Readiness) Draining() bool {
+	return r.draining.Load()
+}
+
+type checkResult struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Handler serves the readiness probe: 200 when every check passes, 503 when
+// one fails or the server is draining. The body reports each check.
+func (r *Readiness) Handler(c *gin.Context) {
+	if r.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": statusDraining})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), r.timeout)
+	defer cancel()
+
+	results := make(map[string]checkResult, len(r.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range r.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			start := time.Now()
+			err := check.Run(ctx)
+			result := checkResult{Status: statusOK, Duration: time.Since(start).String()}
+			if err != nil {
+				result.Status = statusFailed
+				result.Error = err.Error()
+			}
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	status, code := statusOK, http.StatusOK
+	for _, result := range results {
+		if result.Status != statusOK {
+			status, code = statusFailed, http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(code, gin.H{"status": status, "checks": results})
+}
@@ -0,0 +1,111 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func probe(handler gin.HandlerFunc) (int, map[string]interface{}) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/probe", handler)
+
+	req, _ := http.NewRequest("GET", "/probe", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func passing(name string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error { return nil }}
+}
+
+func TestLiveness(t *testing.T) {
+	code, resp := probe(Liveness)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", resp["status"])
+}
+
+func TestReadiness(t *testing.T) {
+	readiness := NewReadiness(time.Second, passing("database"), passing("migrations"))
+
+	code, resp := probe(readiness.Handler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", resp["status"])
+	assert.Len(t, resp["checks"], 2)
+
+	readiness.SetDraining()
+	code, resp = probe(readiness.Handler)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "при остановке сервер перестаёт принимать трафик")
+	assert.Equal(t, "draining", resp["status"])
+}
+
+func TestReadiness_FailedCheck(t *testing.T) {
+	readiness := NewReadiness(time.Second, passing("migrations"), Check{
+		Name: "database",
+		Run:  func(ctx context.Context) error { return errors.New("connection refused") },
+	})
+
+	code, resp := probe(readiness.Handler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "failed", resp["status"])
+	checks := resp["checks"].(map[string]interface{})
+	assert.Equal(t, "failed", checks["database"].(map[string]interface{})["status"])
+	assert.Equal(t, "connection refused", checks["database"].(map[string]interface{})["error"])
+	assert.Equal(t, "ok", checks["migrations"].(map[string]interface{})["status"])
+}
+
+// Зависшая проверка не должна задерживать ответ дольше таймаута
+func TestReadiness_Timeout(t *testing.T) {
+	readiness := NewReadiness(50*time.Millisecond, Check{
+		Name: "database",
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	start := time.Now()
+	code, _ := probe(readiness.Handler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestDatabaseCheck(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	check := DatabaseCheck(db)
+	assert.NoError(t, check.Run(context.Background()))
+	assert.Error(t, check.Run(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type fakeVersioner struct {
+	version, latest int
+}
+
+func (v fakeVersioner) Version(ctx context.Context) (int, error) { return v.version, nil }
+func (v fakeVersioner) Latest() int                              { return v.latest }
+
+func TestMigrationsCheck(t *testing.T) {
+	assert.NoError(t, MigrationsCheck(fakeVersioner{version: 3, latest: 3}).Run(context.Background()))
+	assert.NoError(t, MigrationsCheck(fakeVersioner{version: 4, latest: 3}).Run(context.Background()), "более новая схема допустима")
+	assert.Error(t, MigrationsCheck(fakeVersioner{version: 2, latest: 3}).Run(context.Background()))
+}
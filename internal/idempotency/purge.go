@@ -0,0 +1,62 @@
+// Package idempotency enforces the retention window of stored idempotency
+// keys.
+package idempotency
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const DefaultPurgeInterval = time.Hour
+
+// KeyPurger is implemented by repository.Repository.
+type KeyPurger interface {
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error)
+}
+
+// PurgeWorker periodically deletes idempotency keys, and the responses stored
+// with them, once they are older than the retention window. Without it an
+// expired key is only removed when its employee reuses it.
+type PurgeWorker struct {
+	purger    KeyPurger
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurgeWorker(purger KeyPurger, retention, interval time.Duration) *PurgeWorker {
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+	return &PurgeWorker{purger: purger, retention: retention, interval: interval}
+}
+
+// Run purges expired keys every interval until ctx is cancelled. Each run
+// keeps deleting batches until nothing has expired.
+func (w *PurgeWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.purgeExpired(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *PurgeWorker) purgeExpired(ctx context.Context) {
+	for ctx.Err() == nil {
+		purged, err := w.purger.PurgeIdempotencyKeys(ctx, time.Now().Add(-w.retention))
+		if err != nil {
+			log.Printf("failed to purge idempotency keys: %v", err)
+			return
+		}
+		if purged == 0 {
+			return
+		}
+		log.Printf("purged %d expired idempotency keys", purged)
+	}
+}
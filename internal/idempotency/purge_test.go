@@ -0,0 +1,76 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePurger отдаёт заранее заданные результаты и запоминает границы удаления
+type fakePurger struct {
+	mu      sync.Mutex
+	results []int
+	before  []time.Time
+	err     error
+}
+
+func (f *fakePurger) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.before = append(f.before, before)
+	if f.err != nil {
+		return 0, f.err
+	}
+	if len(f.results) == 0 {
+		return 0, nil
+	}
+	purged := f.results[0]
+	f.results = f.results[1:]
+	return purged, nil
+}
+
+func (f *fakePurger) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.before)
+}
+
+// Воркер удаляет пакеты подряд, пока есть просроченные ключи
+func TestPurgeWorker_DrainsBacklog(t *testing.T) {
+	purger := &fakePurger{results: []int{1000, 1000, 7}}
+	worker := NewPurgeWorker(purger, 24*time.Hour, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return purger.Calls() == 4 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+	assert.Equal(t, 4, purger.Calls(), "после пустого пакета воркер ждёт следующего тика")
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), purger.before[0], time.Minute, "удаляются ключи старше срока хранения")
+}
+
+func TestPurgeWorker_StopsOnError(t *testing.T) {
+	purger := &fakePurger{err: errors.New("db is down")}
+	worker := NewPurgeWorker(purger, 24*time.Hour, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return purger.Calls() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+	assert.Equal(t, 1, purger.Calls(), "ошибка не должна приводить к повторам до следующего тика")
+}
@@ -0,0 +1,144 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AccountID identifies a ledger account. Employee wallets are "wallet:<id>",
+// system accounts have fixed identifiers seeded by the schema.
+type AccountID string
+
+const (
+	// MintAccount is the source of every coin in circulation. Its balance is
+	// always the negated total of coins ever issued.
+	MintAccount AccountID = "mint:issuance"
+	// RevenueAccount collects coins spent on merch.
+	RevenueAccount AccountID = "store:revenue"
+)
+
+const (
+	KindWallet  = "wallet"
+	KindMint    = "mint"
+	KindRevenue = "revenue"
+)
+
+var (
+	ErrUnbalanced     = errors.New("ledger: postings do not sum to zero")
+	ErrTooFewLegs     = errors.New("ledger: entry needs at least two postings")
+	ErrZeroPosting    = errors.New("ledger: posting amount must not be zero")
+	ErrMissingKind    = errors.New("ledger: entry kind is required")
+	ErrMissingAccount = errors.New("ledger: account is required")
+)
+
+func WalletAccount(employeeID int) AccountID {
+	return AccountID(fmt.Sprintf("wallet:%d", employeeID))
+}
+
+// Posting is a single leg of a journal entry. Positive amounts credit the
+// account, negative amounts debit it.
+type Posting struct {
+	Account AccountID
+	Amount  int
+}
+
+// Entry is an immutable journal entry. Its postings must sum to zero.
+type Entry struct {
+	Kind     string
+	Postings []Posting
+}
+
+// Move builds a two-legged entry moving amount coins from one account to another.
+func Move(kind string, from, to AccountID, amount int) Entry {
+	return Entry{
+		Kind: kind,
+		Postings: []Posting{
+			{Account: from, Amount: -amount},
+			{Account: to, Amount: amount},
+		},
+	}
+}
+
+func (e Entry) Validate() error {
+	if e.Kind == "" {
+		return ErrMissingKind
+	}
+	if len(e.Postings) < 2 {
+		return ErrTooFewLegs
+	}
+	sum := 0
+	for _, p := range e.Postings {
+		if p.Account == "" {
+			return ErrMissingAccount
+		}
+		if p.Amount == 0 {
+			return ErrZeroPosting
+		}
+		sum += p.Amount
+	}
+	if sum != 0 {
+		return ErrUnbalanced
+	}
+	return nil
+}
+
+// Execer is the subset of *sql.Tx and *sql.DB used by the ledger.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// OpenWallet creates the wallet account of an employee.
+func OpenWallet(ctx context.Context, q Execer, employeeID int) error {
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO ledger_accounts (id, kind, employee_id, created_at) VALUES ($1, $2, $3, $4)`,
+		WalletAccount(employeeID), KindWallet, employeeID, time.Now(),
+	)
+	return err
+}
+
+// Post validates the entry and writes it with all of its postings. It should
+// run inside the same transaction as the balance change it records.
+func Post(ctx context.Context, q Execer, e Entry) (int64, error) {
+	if err := e.Validate(); err != nil {
+		return 0, err
+	}
+
+	var entryID int64
+	err := q.QueryRowContext(ctx,
+		`INSERT INTO journal_entries (kind, created_at) VALUES ($1, $2) RETURNING id`,
+		e.Kind, time.Now(),
+	).Scan(&entryID)
+	if err != nil {
+		return 0, err
+	}
+
+	values := make([]string, 0, len(e.Postings))
+	args := []interface{}{entryID}
+	for _, p := range e.Postings {
+		values = append(values, fmt.Sprintf("($1, $%d, $%d)", len(args)+1, len(args)+2))
+		args = append(args, p.Account, p.Amount)
+	}
+	_, err = q.ExecContext(ctx,
+		`INSERT INTO ledger_postings (entry_id, account_id, amount) VALUES `+strings.Join(values, ", "),
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return entryID, nil
+}
+
+// Balance derives the balance of an account from its postings.
+func Balance(ctx context.Context, q Execer, account AccountID) (int, error) {
+	var balance int
+	err := q.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM ledger_postings WHERE account_id = $1`,
+		account,
+	).Scan(&balance)
+	return balance, err
+}
@@ -0,0 +1,69 @@
+package ledger
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntryValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		entry Entry
+		err   error
+	}{
+		{"balanced move", Move("transfer", WalletAccount(1), WalletAccount(2), 10), nil},
+		{"unbalanced", Entry{Kind: "x", Postings: []Posting{{MintAccount, -10}, {WalletAccount(1), 5}}}, ErrUnbalanced},
+		{"single leg", Entry{Kind: "x", Postings: []Posting{{MintAccount, 0}}}, ErrTooFewLegs},
+		{"zero posting", Entry{Kind: "x", Postings: []Posting{{MintAccount, 0}, {WalletAccount(1), 0}}}, ErrZeroPosting},
+		{"missing account", Entry{Kind: "x", Postings: []Posting{{"", -1}, {WalletAccount(1), 1}}}, ErrMissingAccount},
+		{"missing kind", Entry{Postings: []Posting{{MintAccount, -1}, {WalletAccount(1), 1}}}, ErrMissingKind},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.err, tc.entry.Validate())
+		})
+	}
+}
+
+func TestPost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	entry := Entry{
+		Kind: "purchase",
+		Postings: []Posting{
+			{WalletAccount(1), -30},
+			{RevenueAccount, 20},
+			{MintAccount, 10},
+		},
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO journal_entries (kind, created_at) VALUES ($1, $2) RETURNING id`)).
+		WithArgs("purchase", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO ledger_postings (entry_id, account_id, amount) VALUES ($1, $2, $3), ($1, $4, $5), ($1, $6, $7)`)).
+		WithArgs(int64(42), "wallet:1", -30, "store:revenue", 20, "mint:issuance", 10).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	id, err := Post(context.Background(), db, entry)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), id)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPost_Unbalanced(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = Post(context.Background(), db, Entry{Kind: "x", Postings: []Posting{{MintAccount, -10}, {WalletAccount(1), 9}}})
+	assert.ErrorIs(t, err, ErrUnbalanced)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
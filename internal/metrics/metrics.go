@@ -0,0 +1,113 @@
+// Package metrics exposes the service metrics to Prometheus.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "merchstore"
+
+// unmatchedRoute labels requests that matched no route, so that scanners
+// cannot blow up the number of series.
+const unmatchedRoute = "unmatched"
+
+// Metrics holds the collectors of the service. Its methods record business
+// events and are safe for concurrent use.
+type Metrics struct {
+	registry         *prometheus.Registry
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	coinsTransferred prometheus.Counter
+	coinsSpent       *prometheus.CounterVec
+	purchaseFailures *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		coinsTransferred: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "coins_transferred_total",
+			Help:      "Coins sent between employees.",
+		}),
+		coinsSpent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "coins_spent_total",
+			Help:      "Coins spent on merch by item.",
+		}, []string{"item"}),
+		purchaseFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "purchase_failures_total",
+			Help:      "Failed purchases and checkouts by reason.",
+		}, []string{"reason"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.coinsTransferred,
+		m.coinsSpent,
+		m.purchaseFailures,
+	)
+	return m
+}
+
+// RegisterDB exports the connection pool statistics of db (sql.DBStats).
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts and times requests by their route template, e.g.
+// /api/buy/:item. It must be registered before gin.Recovery, otherwise a
+// panicking request is not counted at all.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		m.httpDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *Metrics) CoinsTransferred(amount int) {
+	m.coinsTransferred.Add(float64(amount))
+}
+
+func (m *Metrics) CoinsSpent(item string, amount int) {
+	m.coinsSpent.WithLabelValues(item).Add(float64(amount))
+}
+
+func (m *Metrics) PurchaseFailed(reason string) {
+	m.purchaseFailures.WithLabelValues(reason).Inc()
+}
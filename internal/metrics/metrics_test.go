@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/api/buy/:item", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	for _, path := range []string{"/api/buy/pen", "/api/buy/cup", "/wp-admin.php"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Запросы группируются по шаблону маршрута, а не по пути
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/api/buy/:item", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", unmatchedRoute, "404")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.httpDuration))
+}
+
+// Паника считается ответом 500, который отдает gin.Recovery
+func TestMiddleware_Panic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	router := gin.New()
+	router.Use(m.Middleware(), gin.Recovery())
+	router.GET("/api/info", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	req, _ := http.NewRequest("GET", "/api/info", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/api/info", "500")))
+}
+
+func TestBusinessMetrics(t *testing.T) {
+	m := New()
+	m.CoinsTransferred(50)
+	m.CoinsTransferred(25)
+	m.CoinsSpent("pen", 10)
+	m.CoinsSpent("pen", 20)
+	m.PurchaseFailed("insufficient_funds")
+
+	assert.Equal(t, float64(75), testutil.ToFloat64(m.coinsTransferred))
+	assert.Equal(t, float64(30), testutil.ToFloat64(m.coinsSpent.WithLabelValues("pen")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.purchaseFailures.WithLabelValues("insufficient_funds")))
+}
+
+func TestHandler(t *testing.T) {
+	db, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := New()
+	m.RegisterDB(db, "merchstore")
+	m.CoinsTransferred(5)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	body, _ := io.ReadAll(w.Body)
+	for _, name := range []string{"merchstore_coins_transferred_total 5", "go_sql_open_connections", "go_goroutines"} {
+		assert.True(t, strings.Contains(string(body), name), "нет метрики %s", name)
+	}
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"merch-store/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const currentEmployeeKey = "currentEmployee"
+
+var (
+	ErrInvalidIssuer   = errors.New("token has an unexpected issuer")
+	ErrInvalidAudience = errors.New("token has an unexpected audience")
+	ErrMissingClaims   = errors.New("token is missing required claims")
+)
+
+// Claims are the claims of an access token. The subject is the employee id.
+type Claims struct {
+	EmployeeID int      `json:"eid"`
+	Roles      []string `json:"roles"`
+	SessionID  string   `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// Tokens issues and verifies access tokens of one issuer for one audience.
+type Tokens struct {
+	Keys     *KeySet
+	Issuer   string
+	Audience string
+}
+
+func NewTokens(keys *KeySet, issuer, audience string) *Tokens {
+	return &Tokens{Keys: keys, Issuer: issuer, Audience: audience}
+}
+
+func (t *Tokens) issue(employeeID int, roles []string, sessionID string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		EmployeeID: employeeID,
+		Roles:      roles,
+		SessionID:  sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(employeeID),
+			Issuer:    t.Issuer,
+			Audience:  jwt.ClaimStrings{t.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		},
+	}
+	return t.Keys.sign(claims)
+}
+
+// verify checks the signature, expiry, issuer and audience of the token and
+// returns its claims.
+func (t *Tokens) verify(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := t.Keys.parser().ParseWithClaims(tokenStr, claims, t.Keys.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.VerifyIssuer(t.Issuer, true) {
+		return nil, ErrInvalidIssuer
+	}
+	if !claims.VerifyAudience(t.Audience, true) {
+		return nil, ErrInvalidAudience
+	}
+	if claims.ExpiresAt == nil || claims.EmployeeID <= 0 || claims.Subject != strconv.Itoa(claims.EmployeeID) {
+		return nil, ErrMissingClaims
+	}
+	return claims, nil
+}
+
+// AuthenticatedEmployee is the employee a request is made on behalf of.
+type AuthenticatedEmployee struct {
+	ID        int
+	Roles     []string
+	SessionID string
+}
+
+func (e AuthenticatedEmployee) HasRole(role string) bool {
+	for _, r := range e.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCurrentEmployee stores the authenticated employee in the request
+// context. It is called by JWTAuthMiddleware.
+func SetCurrentEmployee(c *gin.Context, employee AuthenticatedEmployee) {
+	c.Set(currentEmployeeKey, employee)
+}
+
+// CurrentEmployee returns the authenticated employee of the request. When
+// there is none it responds with 401 and returns false, so handlers can
+// simply return.
+func CurrentEmployee(c *gin.Context) (AuthenticatedEmployee, bool) {
+	value, exists := c.Get(currentEmployeeKey)
+	employee, ok := value.(AuthenticatedEmployee)
+	if !exists || !ok {
+		AbortWithError(c, apierror.Unauthorized("user not found in context"))
+		return AuthenticatedEmployee{}, false
+	}
+	return employee, true
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"regexp"
+
+	"merch-store/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	requestIDKey    = "requestID"
+)
+
+// validRequestID limits the ids accepted from clients and proxies, since they
+// end up in logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID tags the request with an id that is echoed in the X-Request-ID
+// header and in error responses. An id set by the client or a proxy is kept.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			b := make([]byte, 16)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// CurrentRequestID returns the id assigned by RequestID, or "" when the
+// middleware is not installed.
+func CurrentRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// AbortWithError stops the request with err. The response is written by
+// ErrorHandler.
+func AbortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// ErrorHandler writes the response for the last error of the request, see
+// apierror.From. It must be the first middleware after RequestID so that it
+// sees the errors of all the others.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		renderError(c)
+	}
+}
+
+type errorResponse struct {
+	Errors    string                 `json:"errors"`
+	Code      string                 `json:"code"`
+	RequestID string                 `json:"requestId,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// renderError writes the error response unless the handler already wrote a
+// response. Server errors are logged with their cause.
+func renderError(c *gin.Context) {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+	apiErr := apierror.From(c.Errors.Last().Err)
+	requestID := CurrentRequestID(c)
+	if apiErr.Status >= 500 {
+		log.Printf("request %s: %v", requestID, apiErr)
+	}
+	c.JSON(apiErr.Status, errorResponse{
+		Errors:    apiErr.Message,
+		Code:      apiErr.Code,
+		RequestID: requestID,
+		Details:   apiErr.Details,
+	})
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/apierror"
+	"merch-store/internal/repository"
+)
+
+func newErrorRouter(err error) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), ErrorHandler())
+	router.GET("/test", func(c *gin.Context) {
+		AbortWithError(c, err)
+	})
+	return router
+}
+
+func TestErrorHandler(t *testing.T) {
+	router := newErrorRouter(apierror.NotFound("employee not found").WithDetails(map[string]interface{}{"username": "bob"}))
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "req-1", w.Header().Get(RequestIDHeader))
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "employee not found", resp["errors"])
+	assert.Equal(t, apierror.CodeNotFound, resp["code"])
+	assert.Equal(t, "req-1", resp["requestId"])
+	assert.Equal(t, map[string]interface{}{"username": "bob"}, resp["details"])
+}
+
+func TestErrorHandler_RepositoryError(t *testing.T) {
+	router := newErrorRouter(repository.ErrInsufficientFunds)
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, apierror.CodeInsufficientFunds, resp["code"])
+	assert.NotEmpty(t, resp["requestId"], "без заголовка идентификатор запроса генерируется")
+	assert.Equal(t, resp["requestId"], w.Header().Get(RequestIDHeader))
+}
+
+// Текст ошибки базы данных не должен попадать в ответ
+func TestErrorHandler_HidesInternalErrors(t *testing.T) {
+	router := newErrorRouter(errors.New(`pq: relation "employees" does not exist`))
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set(RequestIDHeader, "not a valid id!")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "pq:")
+	assert.NotEqual(t, "not a valid id!", w.Header().Get(RequestIDHeader))
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, apierror.CodeInternal, resp["code"])
+}
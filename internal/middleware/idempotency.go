@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"merch-store/internal/apierror"
+	"merch-store/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	idempotencyContentType   = "application/json; charset=utf-8"
+	// maxIdempotentBodyBytes bounds the body read into memory to fingerprint
+	// it. The API's request bodies are a few hundred bytes at most.
+	maxIdempotentBodyBytes = 1 << 20
+)
+
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, employeeID int, key, fingerprint string, retention time.Duration) (models.IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, employeeID int, key string, statusCode int, responseBody []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, employeeID int, key string) error
+}
+
+// Idempotency makes a route safe to retry. A request carrying an
+// Idempotency-Key header runs at most once per employee and key within the
+// retention window; retries get the stored response back. It must run after
+// JWTAuthMiddleware.
+func Idempotency(store IdempotencyStore, retention time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			AbortWithError(c, apierror.InvalidRequest("Idempotency-Key is too long"))
+			return
+		}
+
+		employee, ok := CurrentEmployee(c)
+		if !ok {
+			return
+		}
+		userID := employee.ID
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodyBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			AbortWithError(c, apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeInvalidRequest, "request body is too large"))
+			return
+		}
+		if err != nil {
+			AbortWithError(c, apierror.InvalidRequest("cannot read request body").Wrap(err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(c.Request, body)
+		record, reserved, err := store.ReserveIdempotencyKey(c.Request.Context(), userID, key, fingerprint, retention)
+		if err != nil {
+			AbortWithError(c, apierror.Internal("cannot reserve idempotency key").Wrap(err))
+			return
+		}
+		if !reserved {
+			switch {
+			case record.Fingerprint != fingerprint:
+				AbortWithError(c, apierror.New(http.StatusUnprocessableEntity, apierror.CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request"))
+			case !record.Completed:
+				AbortWithError(c, apierror.New(http.StatusConflict, apierror.CodeConflict, "a request with this Idempotency-Key is still in progress"))
+			default:
+				c.Header(IdempotentReplayedHeader, "true")
+				c.Data(record.StatusCode, idempotencyContentType, record.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		// Server errors are not remembered so that the client can retry them.
+		// A response that could not be stored keeps the key reserved instead:
+		// the request may have moved coins, so it must not run again. The
+		// outcome is stored even if the client has gone away meanwhile.
+		ctx := context.WithoutCancel(c.Request.Context())
+		release := func() {
+			if err := store.ReleaseIdempotencyKey(ctx, userID, key); err != nil {
+				log.Printf("failed to release idempotency key %q: %v", key, err)
+			}
+		}
+		// A panicking handler is a server error too; the panic goes on to the
+		// recovery middleware.
+		defer func() {
+			if p := recover(); p != nil {
+				release()
+				panic(p)
+			}
+		}()
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		// The error response is written here rather than by ErrorHandler so
+		// that it is captured.
+		renderError(c)
+
+		if writer.Status() >= http.StatusInternalServerError {
+			release()
+			return
+		}
+		if err := store.CompleteIdempotencyKey(ctx, userID, key, writer.Status(), writer.body.Bytes()); err != nil {
+			log.Printf("failed to store response for idempotency key %q: %v", key, err)
+		}
+	}
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/apierror"
+	"merch-store/internal/models"
+)
+
+// memoryIdempotencyStore – хранилище ключей идемпотентности в памяти
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyRecord
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]models.IdempotencyRecord)}
+}
+
+func (s *memoryIdempotencyStore) ReserveIdempotencyKey(ctx context.Context, employeeID int, key, fingerprint string, retention time.Duration) (models.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok && time.Since(record.CreatedAt) < retention {
+		return record, false, nil
+	}
+	record := models.IdempotencyRecord{Key: key, Fingerprint: fingerprint, CreatedAt: time.Now()}
+	s.records[key] = record
+	return record, true, nil
+}
+
+func (s *memoryIdempotencyStore) CompleteIdempotencyKey(ctx context.Context, employeeID int, key string, statusCode int, responseBody []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[key]
+	record.Completed = true
+	record.StatusCode = statusCode
+	record.ResponseBody = responseBody
+	s.records[key] = record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, employeeID int, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func newIdempotentRouter(store IdempotencyStore, status int, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		SetCurrentEmployee(c, AuthenticatedEmployee{ID: 1})
+		c.Next()
+	})
+	router.POST("/pay", Idempotency(store, time.Hour), func(c *gin.Context) {
+		*calls++
+		c.JSON(status, gin.H{"call": *calls})
+	})
+	return router
+}
+
+func doIdempotent(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/pay", bytes.NewBufferString(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysResponse(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(newMemoryIdempotencyStore(), http.StatusOK, &calls)
+
+	first := doIdempotent(router, "key-1", `{"amount": 10}`)
+	second := doIdempotent(router, "key-1", `{"amount": 10}`)
+
+	assert.Equal(t, 1, calls, "обработчик должен выполниться один раз")
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+}
+
+func TestIdempotency_DifferentBody(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(newMemoryIdempotencyStore(), http.StatusOK, &calls)
+
+	doIdempotent(router, "key-1", `{"amount": 10}`)
+	w := doIdempotent(router, "key-1", `{"amount": 20}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_WithoutKey(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(newMemoryIdempotencyStore(), http.StatusOK, &calls)
+
+	doIdempotent(router, "", `{"amount": 10}`)
+	doIdempotent(router, "", `{"amount": 10}`)
+
+	assert.Equal(t, 2, calls, "запросы без ключа не дедуплицируются")
+}
+
+func TestIdempotency_ServerErrorIsNotRemembered(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(newMemoryIdempotencyStore(), http.StatusInternalServerError, &calls)
+
+	doIdempotent(router, "key-1", `{}`)
+	doIdempotent(router, "key-1", `{}`)
+
+	assert.Equal(t, 2, calls, "после ошибки сервера запрос можно повторить")
+}
+
+func TestIdempotency_InProgress(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	router := newIdempotentRouter(store, http.StatusOK, &calls)
+
+	_, _, err := store.ReserveIdempotencyKey(context.Background(), 1, "key-1", requestFingerprint(httptest.NewRequest("POST", "/pay", nil), []byte(`{}`)), time.Hour)
+	assert.NoError(t, err)
+
+	w := doIdempotent(router, "key-1", `{}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 0, calls)
+}
+
+// Ответ с ошибкой клиента пишется до сохранения, поэтому повтор получает то же тело
+func TestIdempotency_ReplaysErrorResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		SetCurrentEmployee(c, AuthenticatedEmployee{ID: 1})
+		c.Next()
+	})
+	router.POST("/pay", Idempotency(newMemoryIdempotencyStore(), time.Hour), func(c *gin.Context) {
+		calls++
+		AbortWithError(c, apierror.New(http.StatusBadRequest, apierror.CodeInsufficientFunds, "insufficient funds"))
+	})
+
+	first := doIdempotent(router, "key-1", `{}`)
+	second := doIdempotent(router, "key-1", `{}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusBadRequest, second.Code)
+	assert.Contains(t, first.Body.String(), "insufficient_funds")
+	assert.Equal(t, first.Body.String(), second.Body.String())
+}
+
+// Паника в обработчике освобождает ключ, иначе повтор получал бы 409 до истечения срока
+func TestIdempotency_PanicReleasesKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	router := gin.New()
+	router.Use(gin.Recovery(), ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		SetCurrentEmployee(c, AuthenticatedEmployee{ID: 1})
+		c.Next()
+	})
+	router.POST("/pay", Idempotency(store, time.Hour), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		c.JSON(http.StatusOK, gin.H{"call": calls})
+	})
+
+	first := doIdempotent(router, "key-1", `{}`)
+	assert.Equal(t, http.StatusInternalServerError, first.Code)
+
+	second := doIdempotent(router, "key-1", `{}`)
+	assert.Equal(t, http.StatusOK, second.Code, "после паники запрос можно повторить")
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_BodyTooLarge(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(newMemoryIdempotencyStore(), http.StatusOK, &calls)
+
+	w := doIdempotent(router, "key-1", strings.Repeat("x", maxIdempotentBodyBytes+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, 0, calls)
+}
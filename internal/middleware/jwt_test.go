@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -10,12 +11,24 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"merch-store/internal/models"
 )
 
+// testKeys – ключи, которыми подписываются токены в тестах
+var testKeys, _ = GenerateKeySet()
+
+var testTokens = NewTokens(testKeys, "merch-store", "merch-store")
+
 func TestJWTAuthMiddleware_MissingHeader(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.Use(JWTAuthMiddleware("mysecret"))
+	router.Use(ErrorHandler())
+	router.Use(JWTAuthMiddleware(testTokens, nil))
 	router.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
@@ -29,13 +42,14 @@ func TestJWTAuthMiddleware_MissingHeader(t *testing.T) {
 
 	var resp map[string]string
 	_ = json.NewDecoder(w.Body).Decode(&resp)
-	assert.Equal(t, "Authorization header is missing", resp["error"])
+	assert.Equal(t, "Authorization header is missing", resp["errors"])
 }
 
 func TestJWTAuthMiddleware_InvalidHeaderFormat(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.Use(JWTAuthMiddleware("mysecret"))
+	router.Use(ErrorHandler())
+	router.Use(JWTAuthMiddleware(testTokens, nil))
 	router.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
@@ -49,13 +63,14 @@ func TestJWTAuthMiddleware_InvalidHeaderFormat(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 	var resp map[string]string
 	_ = json.NewDecoder(w.Body).Decode(&resp)
-	assert.Equal(t, "Authorization header format must be Bearer {token}", resp["error"])
+	assert.Equal(t, "Authorization header format must be Bearer {token}", resp["errors"])
 }
 
 func TestJWTAuthMiddleware_InvalidToken(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.Use(JWTAuthMiddleware("mysecret"))
+	router.Use(ErrorHandler())
+	router.Use(JWTAuthMiddleware(testTokens, nil))
 	router.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
@@ -69,25 +84,25 @@ func TestJWTAuthMiddleware_InvalidToken(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 	var resp map[string]string
 	_ = json.NewDecoder(w.Body).Decode(&resp)
-	assert.Equal(t, "Invalid token", resp["error"])
+	assert.Equal(t, "Invalid token", resp["errors"])
 }
 
 func TestJWTAuthMiddleware_ValidToken(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	tokenStr, err := GenerateJWT(123, "mysecret")
+	tokenStr, err := GenerateJWT(123, models.RoleEmployee, "session-1", testTokens)
 	assert.NoError(t, err)
 
 	router := gin.New()
-	router.Use(JWTAuthMiddleware("mysecret"))
+	router.Use(ErrorHandler())
+	router.Use(JWTAuthMiddleware(testTokens, nil))
 
 	router.GET("/test", func(c *gin.Context) {
-		userID, exists := c.Get("userID")
-		if !exists {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "userID not set"})
+		employee, ok := CurrentEmployee(c)
+		if !ok {
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"userID": userID})
+		c.JSON(http.StatusOK, gin.H{"userID": employee.ID})
 	})
 
 	req, _ := http.NewRequest("GET", "/test", nil)
@@ -106,27 +121,200 @@ func TestJWTAuthMiddleware_ValidToken(t *testing.T) {
 }
 
 func TestGenerateJWT(t *testing.T) {
-	secret := "mysecret"
 	userID := 456
-	tokenStr, err := GenerateJWT(userID, secret)
+	tokenStr, err := GenerateJWT(userID, models.RoleStoreManager, "session-1", testTokens)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, tokenStr)
 
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+	token, err := testKeys.parser().Parse(tokenStr, testKeys.keyFunc)
 	assert.NoError(t, err)
 	assert.True(t, token.Valid)
+	assert.Equal(t, AlgEdDSA, token.Header["alg"])
+	assert.Equal(t, testKeys.signingKID, token.Header["kid"])
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	assert.True(t, ok)
+	claims, err := testTokens.verify(tokenStr)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, claims.EmployeeID)
+	assert.Equal(t, "456", claims.Subject)
+	assert.Equal(t, []string{models.RoleStoreManager}, claims.Roles)
+	assert.Equal(t, "session-1", claims.SessionID)
+	assert.Equal(t, "merch-store", claims.Issuer)
+	assert.True(t, claims.ExpiresAt.After(time.Now()))
+}
 
-	uid, ok := claims["userID"].(float64)
-	assert.True(t, ok)
-	assert.Equal(t, float64(userID), uid)
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-	exp, ok := claims["exp"].(float64)
-	assert.True(t, ok)
-	now := time.Now().Unix()
-	assert.True(t, int64(exp) > now)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.Use(JWTAuthMiddleware(testTokens, nil))
+	router.GET("/admin", RequireRole(models.RoleFinanceAdmin), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	cases := []struct {
+		role string
+		code int
+	}{
+		{models.RoleFinanceAdmin, http.StatusOK},
+		{models.RoleStoreManager, http.StatusForbidden},
+		{models.RoleEmployee, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		tokenStr, err := GenerateJWT(1, tc.role, "session-1", testTokens)
+		assert.NoError(t, err)
+
+		req, _ := http.NewRequest("GET", "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tc.code, w.Code, "роль %s", tc.role)
+	}
+}
+
+// Токены чужого издателя или для другого получателя, а также токены без
+// обязательных полей не принимаются, даже если подпись верна
+func TestJWTAuthMiddleware_RejectsForeignTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.Use(JWTAuthMiddleware(testTokens, nil))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	foreignIssuer := NewTokens(testKeys, "other-service", "merch-store")
+	foreignAudience := NewTokens(testKeys, "merch-store", "other-service")
+	issuedByOther, err := GenerateJWT(1, models.RoleEmployee, "session-1", foreignIssuer)
+	assert.NoError(t, err)
+	issuedForOther, err := GenerateJWT(1, models.RoleEmployee, "session-1", foreignAudience)
+	assert.NoError(t, err)
+	withoutEmployee, err := testKeys.sign(jwt.MapClaims{
+		"iss": "merch-store",
+		"aud": "merch-store",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+	withoutExpiry, err := testKeys.sign(jwt.MapClaims{
+		"iss": "merch-store",
+		"aud": "merch-store",
+		"sub": "1",
+		"eid": 1,
+	})
+	assert.NoError(t, err)
+
+	for name, tokenStr := range map[string]string{
+		"чужой издатель":     issuedByOther,
+		"чужой получатель":   issuedForOther,
+		"нет сотрудника":     withoutEmployee,
+		"нет срока действия": withoutExpiry,
+	} {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+	}
+}
+
+func TestCurrentEmployee_Missing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/test", func(c *gin.Context) {
+		if _, ok := CurrentEmployee(c); !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// memorySessions – сессии в памяти: true означает, что сессия отозвана
+type memorySessions map[string]bool
+
+func (m memorySessions) SessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	revoked, ok := m[sessionID]
+	return revoked || !ok, nil
+}
+
+func TestJWTAuthMiddleware_RevokedSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sessions := memorySessions{"active": false, "revoked": true}
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.Use(JWTAuthMiddleware(testTokens, sessions))
+	router.GET("/test", func(c *gin.Context) {
+		employee, _ := CurrentEmployee(c)
+		c.JSON(http.StatusOK, gin.H{"sessionID": employee.SessionID})
+	})
+
+	cases := []struct {
+		sessionID string
+		code      int
+	}{
+		{"active", http.StatusOK},
+		{"revoked", http.StatusUnauthorized},
+		{"unknown", http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		tokenStr, err := GenerateJWT(1, models.RoleEmployee, tc.sessionID, testTokens)
+		assert.NoError(t, err)
+
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tc.code, w.Code, "сессия %q", tc.sessionID)
+	}
+}
+
+// spanSessions запоминает спан, в контексте которого проверялась сессия
+type spanSessions struct {
+	span trace.SpanContext
+}
+
+func (s *spanSessions) SessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	s.span = trace.SpanContextFromContext(ctx)
+	return false, nil
+}
+
+func TestJWTAuthMiddleware_Span(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	sessions := &spanSessions{}
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.Use(JWTAuthMiddleware(testTokens, sessions))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tokenStr, err := GenerateJWT(1, models.RoleEmployee, "active", testTokens)
+	assert.NoError(t, err)
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenStr)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Проверка сессии идёт внутри спана аутентификации
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "JWTAuthMiddleware", spans[0].Name())
+		assert.Equal(t, spans[0].SpanContext().SpanID(), sessions.span.SpanID())
+	}
 }
@@ -0,0 +1,239 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	minRSAKeyBits = 2048
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported key type, expected RSA or Ed25519")
+	ErrUnknownKeyID   = errors.New("unknown key id")
+)
+
+type verificationKey struct {
+	alg    string
+	public crypto.PublicKey
+}
+
+// KeySet holds the private key new tokens are signed with and every public
+// key tokens are accepted from. Keys are identified by their RFC 7638
+// thumbprint, which is put into the kid header of issued tokens.
+type KeySet struct {
+	signingKID string
+	signingAlg string
+	signingKey crypto.PrivateKey
+	keys       map[string]verificationKey
+	kids       []string
+}
+
+// NewKeySet builds a key set signing with signingKey. The public part of
+// signingKey is always accepted; verificationKeys are additional keys, e.g.
+// the previous signing key during rotation.
+func NewKeySet(signingKey crypto.PrivateKey, verificationKeys ...crypto.PublicKey) (*KeySet, error) {
+	signer, ok := signingKey.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	ks := &KeySet{signingKey: signingKey, keys: make(map[string]verificationKey)}
+	kid, alg, err := ks.add(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	ks.signingKID, ks.signingAlg = kid, alg
+
+	for _, key := range verificationKeys {
+		if _, _, err = ks.add(key); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// GenerateKeySet returns a key set with a fresh Ed25519 key. Tokens signed
+// with it become invalid when the process exits.
+func GenerateKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(private)
+}
+
+// LoadKeySet reads a PEM encoded private key (PKCS #8 or PKCS #1) and PEM
+// encoded public keys (PKIX or PKCS #1) from files.
+func LoadKeySet(signingKeyFile string, verificationKeyFiles []string) (*KeySet, error) {
+	block, err := readPEM(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	signingKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		signingKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse signing key %s: %w", signingKeyFile, err)
+	}
+
+	verificationKeys := make([]crypto.PublicKey, 0, len(verificationKeyFiles))
+	for _, file := range verificationKeyFiles {
+		block, err = readPEM(file)
+		if err != nil {
+			return nil, err
+		}
+		var key crypto.PublicKey
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse verification key %s: %w", file, err)
+		}
+		verificationKeys = append(verificationKeys, key)
+	}
+
+	return NewKeySet(signingKey, verificationKeys...)
+}
+
+func readPEM(file string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", file)
+	}
+	return block, nil
+}
+
+func (ks *KeySet) add(public crypto.PublicKey) (string, string, error) {
+	var alg string
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return "", "", fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+		alg = AlgRS256
+	case ed25519.PublicKey:
+		alg = AlgEdDSA
+	default:
+		return "", "", ErrUnsupportedKey
+	}
+
+	kid := thumbprint(public)
+	if _, ok := ks.keys[kid]; !ok {
+		ks.keys[kid] = verificationKey{alg: alg, public: public}
+		ks.kids = append(ks.kids, kid)
+	}
+	return kid, alg, nil
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	method := jwt.GetSigningMethod(ks.signingAlg)
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = ks.signingKID
+	return token.SignedString(ks.signingKey)
+}
+
+// keyFunc returns the key named by the kid header, and only if the token is
+// signed with the algorithm of that key. Pinning the algorithm to the key
+// prevents an attacker from choosing e.g. HS256 with a public key as secret.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("unexpected signing algorithm %s for key %s", token.Method.Alg(), kid)
+	}
+	return key.public, nil
+}
+
+func (ks *KeySet) parser() *jwt.Parser {
+	return jwt.NewParser(jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}))
+}
+
+// JWK is a public key in the JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns every verification key, so that other services can verify the
+// tokens we issue.
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(ks.kids))}
+	for _, kid := range ks.kids {
+		key := ks.keys[kid]
+		jwk := publicJWK(key.public)
+		jwk.Use = "sig"
+		jwk.Alg = key.alg
+		jwk.Kid = kid
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func publicJWK(public crypto.PublicKey) JWK {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}
+	}
+	return JWK{}
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint: the SHA-256 of the
+// required members of the JWK in lexicographic order.
+func thumbprint(public crypto.PublicKey) string {
+	jwk := publicJWK(public)
+	var canonical string
+	switch jwk.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, jwk.E, jwk.N)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, jwk.Crv, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func verifyToken(ks *KeySet, tokenStr string) error {
+	_, err := ks.parser().Parse(tokenStr, ks.keyFunc)
+	return err
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{"userID": 1, "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+	assert.NoError(t, err)
+	return path
+}
+
+// Новый RSA ключ подписывает токены, а токены старого Ed25519 ключа ещё принимаются
+func TestLoadKeySet_Rotation(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	assert.NoError(t, err)
+	signingFile := writePEM(t, dir, "signing.pem", "PRIVATE KEY", der)
+
+	oldPublic, oldPrivate, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err = x509.MarshalPKIXPublicKey(oldPublic)
+	assert.NoError(t, err)
+	oldFile := writePEM(t, dir, "old.pub", "PUBLIC KEY", der)
+
+	ks, err := LoadKeySet(signingFile, []string{oldFile})
+	assert.NoError(t, err)
+
+	tokenStr, err := ks.sign(testClaims())
+	assert.NoError(t, err)
+	assert.NoError(t, verifyToken(ks, tokenStr))
+
+	oldKeys, err := NewKeySet(oldPrivate)
+	assert.NoError(t, err)
+	oldToken, err := oldKeys.sign(testClaims())
+	assert.NoError(t, err)
+	assert.NoError(t, verifyToken(ks, oldToken), "токены старого ключа принимаются во время ротации")
+
+	jwks := ks.JWKS()
+	if assert.Len(t, jwks.Keys, 2) {
+		assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+		assert.Equal(t, AlgRS256, jwks.Keys[0].Alg)
+		assert.Equal(t, ks.signingKID, jwks.Keys[0].Kid)
+		assert.Equal(t, "AQAB", jwks.Keys[0].E)
+		assert.Equal(t, "OKP", jwks.Keys[1].Kty)
+		assert.Equal(t, AlgEdDSA, jwks.Keys[1].Alg)
+		assert.Equal(t, oldKeys.signingKID, jwks.Keys[1].Kid)
+	}
+}
+
+func TestLoadKeySet_InvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "garbage.pem")
+	assert.NoError(t, os.WriteFile(path, []byte("not a key"), 0o600))
+
+	_, err := LoadKeySet(path, nil)
+	assert.Error(t, err)
+
+	_, err = LoadKeySet(filepath.Join(dir, "missing.pem"), nil)
+	assert.Error(t, err)
+}
+
+func TestNewKeySet_RejectsWeakRSA(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	_, err = NewKeySet(weak)
+	assert.Error(t, err)
+}
+
+// Токен с HS256, подписанный открытым ключом как секретом, не принимается
+func TestKeySet_RejectsAlgorithmConfusion(t *testing.T) {
+	public := testKeys.keys[testKeys.signingKID].public.(ed25519.PublicKey)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	token.Header["kid"] = testKeys.signingKID
+	tokenStr, err := token.SignedString([]byte(public))
+	assert.NoError(t, err)
+	assert.Error(t, verifyToken(testKeys, tokenStr))
+
+	token = jwt.NewWithClaims(jwt.SigningMethodNone, testClaims())
+	token.Header["kid"] = testKeys.signingKID
+	tokenStr, err = token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+	assert.Error(t, verifyToken(testKeys, tokenStr))
+}
+
+// Алгоритм токена должен совпадать с алгоритмом ключа из kid
+func TestKeySet_RejectsAlgorithmOfAnotherKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims())
+	token.Header["kid"] = testKeys.signingKID
+	tokenStr, err := token.SignedString(rsaKey)
+	assert.NoError(t, err)
+
+	assert.Error(t, verifyToken(testKeys, tokenStr))
+}
+
+func TestKeySet_RejectsUnknownKeyID(t *testing.T) {
+	otherKeys, err := GenerateKeySet()
+	assert.NoError(t, err)
+	tokenStr, err := otherKeys.sign(testClaims())
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, verifyToken(testKeys, tokenStr), ErrUnknownKeyID)
+}
+
+// Пример из RFC 8037, приложение A.3
+func TestThumbprint_Ed25519(t *testing.T) {
+	x, err := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", thumbprint(ed25519.PublicKey(x)))
+}
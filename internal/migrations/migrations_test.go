@@ -0,0 +1,245 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, migrations) {
+		assert.Equal(t, 1, migrations[0].Version)
+		assert.Contains(t, migrations[0].Up, "CREATE TABLE IF NOT EXISTS employees")
+	}
+	for i := 1; i < len(migrations); i++ {
+		assert.Less(t, migrations[i-1].Version, migrations[i].Version, "миграции должны идти по возрастанию версий")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"нет down-файла": {
+			"sql/0001_init.up.sql": {Data: []byte("SELECT 1")},
+		},
+		"неверное имя файла": {
+			"sql/0001_init.sql": {Data: []byte("SELECT 1")},
+		},
+		"разные имена одной версии": {
+			"sql/0001_init.up.sql":    {Data: []byte("SELECT 1")},
+			"sql/0001_other.down.sql": {Data: []byte("SELECT 1")},
+		},
+	}
+	for name, fsys := range cases {
+		_, err := load(fsys, "sql")
+		assert.Error(t, err, name)
+	}
+}
+
+func newTestMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	migrator := &Migrator{db: db, migrations: []Migration{
+		{Version: 1, Name: "init", Up: "CREATE TABLE a (id INT)", Down: "DROP TABLE a"},
+		{Version: 2, Name: "b", Up: "CREATE TABLE b (id INT)", Down: "DROP TABLE b"},
+	}}
+	return migrator, mock
+}
+
+func expectLocked(mock sqlmock.Sqlmock, current int) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).
+		WithArgs(lockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(current))
+}
+
+func expectUnlocked(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).
+		WithArgs(lockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// Применяются только миграции новее версии базы, каждая в своей транзакции
+func TestUp(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+
+	expectLocked(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE b (id INT)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`)).
+		WithArgs(2, "b").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlocked(mock)
+
+	applied, err := migrator.Up(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2}, applied)
+	assert.Equal(t, 2, migrator.Latest())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUp_Failure(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+
+	expectLocked(mock, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE a (id INT)`)).
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+	expectUnlocked(mock)
+
+	applied, err := migrator.Up(context.Background())
+	assert.ErrorIs(t, err, sql.ErrConnDone)
+	assert.Empty(t, applied, "следующие миграции не применяются после ошибки")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDown(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+
+	expectLocked(mock, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE b`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM schema_migrations WHERE version = $1`)).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlocked(mock)
+
+	reverted, err := migrator.Down(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2}, reverted)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Интеграционный тест: требует живой Postgres в TEST_DATABASE_URL
+func TestMigrations_UpDownUp(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := New(db)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	version, err := migrator.Version(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, migrator.Latest(), version)
+
+	// Повторный запуск ничего не делает
+	applied, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	_, err = migrator.Down(ctx, len(migrator.migrations))
+	assert.NoError(t, err)
+	version, err = migrator.Version(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+}
+
+// Интеграционный тест: база, созданная прежним db/init.sql, обновляется до
+// последней версии с сохранением сотрудников и их балансов
+func TestMigrations_UpgradeFromInitSQL(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := New(db)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, `DROP SCHEMA public CASCADE; CREATE SCHEMA public`)
+	assert.NoError(t, err)
+	_, err = db.ExecContext(ctx, migrator.migrations[0].Up)
+	assert.NoError(t, err)
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO employees (username, coin_balance) VALUES ('alice', 940), ('bob', 1060), ('carol', 0);
+		INSERT INTO purchases (employee_id, merch_name, price, quantity) VALUES (1, 'cup', 20, 3);
+		INSERT INTO transactions (employee_id, counterparty_id, amount, transaction_type) VALUES (1, 2, 60, 'transfer');
+	`)
+	assert.NoError(t, err)
+
+	applied, err := migrator.Up(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, applied, len(migrator.migrations), "база init.sql принимается как пустая история")
+
+	// У каждого сотрудника есть кошелёк с начальным балансом в журнале
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.username, e.coin_balance, COALESCE(SUM(p.amount), 0)
+		FROM employees e
+		JOIN ledger_accounts a ON a.employee_id = e.id
+		LEFT JOIN ledger_postings p ON p.account_id = a.id
+		GROUP BY e.id
+		ORDER BY e.id
+	`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rows.Close()
+	wallets := 0
+	for rows.Next() {
+		var username string
+		var cached, derived int
+		assert.NoError(t, rows.Scan(&username, &cached, &derived))
+		assert.Equal(t, cached, derived, "баланс %s в журнале", username)
+		wallets++
+	}
+	assert.Equal(t, 3, wallets)
+
+	var items int
+	assert.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM merch_items WHERE active`).Scan(&items))
+	assert.Equal(t, 10, items, "каталог заполняется при обновлении")
+
+	// Прежний перевод записан одной строкой отправителя с положительной суммой
+	var sent, received int
+	assert.NoError(t, db.QueryRowContext(ctx, `SELECT amount FROM transactions WHERE employee_id = 1 AND counterparty_id = 2`).Scan(&sent))
+	assert.NoError(t, db.QueryRowContext(ctx, `SELECT amount FROM transactions WHERE employee_id = 2 AND counterparty_id = 1`).Scan(&received))
+	assert.Equal(t, -60, sent, "отправитель видит перевод отправленным")
+	assert.Equal(t, 60, received, "получатель видит перевод полученным")
+
+	var role, hash string
+	assert.NoError(t, db.QueryRowContext(ctx, `SELECT role, password_hash FROM employees WHERE username = 'alice'`).Scan(&role, &hash))
+	assert.Equal(t, "employee", role)
+	assert.Empty(t, hash)
+}
@@ -1,3 +1,6 @@
+-- Baseline: db/init.sql as it shipped before migrations existed. Databases
+-- created from it already have these tables and adopt them as version 1.
+
 CREATE TABLE IF NOT EXISTS employees (
     id SERIAL PRIMARY KEY,
     username TEXT UNIQUE NOT NULL,
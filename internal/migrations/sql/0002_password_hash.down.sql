@@ -0,0 +1 @@
+ALTER TABLE employees DROP COLUMN password_hash;
@@ -0,0 +1,4 @@
+-- Employees registered before passwords existed get an empty hash. No
+-- password matches it, so they can only sign in through SSO.
+ALTER TABLE employees ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE employees ALTER COLUMN password_hash DROP DEFAULT;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS transactions_transfer_id_idx;
+ALTER TABLE transactions DROP COLUMN IF EXISTS transfer_id;
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS ledger_accounts;
+DROP FUNCTION IF EXISTS ledger_check_entry_balanced();
+DROP FUNCTION IF EXISTS ledger_reject_mutation();
+ALTER TABLE employees DROP CONSTRAINT IF EXISTS employees_coin_balance_check;
@@ -0,0 +1,62 @@
+ALTER TABLE employees ADD CONSTRAINT employees_coin_balance_check CHECK (coin_balance >= 0);
+
+CREATE TABLE ledger_accounts (
+    id TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    employee_id INT UNIQUE REFERENCES employees(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO ledger_accounts (id, kind) VALUES
+    ('mint:issuance', 'mint'),
+    ('store:revenue', 'revenue');
+
+CREATE TABLE journal_entries (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE ledger_postings (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES journal_entries(id),
+    account_id TEXT NOT NULL REFERENCES ledger_accounts(id),
+    amount INT NOT NULL CHECK (amount <> 0)
+);
+
+CREATE INDEX ledger_postings_account_id_idx ON ledger_postings (account_id);
+CREATE INDEX ledger_postings_entry_id_idx ON ledger_postings (entry_id);
+
+-- Journal entries and postings are append-only.
+CREATE FUNCTION ledger_reject_mutation() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER journal_entries_append_only
+    BEFORE UPDATE OR DELETE ON journal_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_reject_mutation();
+
+CREATE TRIGGER ledger_postings_append_only
+    BEFORE UPDATE OR DELETE ON ledger_postings
+    FOR EACH ROW EXECUTE FUNCTION ledger_reject_mutation();
+
+-- Every journal entry must balance by the time its transaction commits.
+CREATE FUNCTION ledger_check_entry_balanced() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM ledger_postings WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % does not balance', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_postings_balanced
+    AFTER INSERT ON ledger_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION ledger_check_entry_balanced();
+
+-- Both sides of a transfer point at its journal entry.
+ALTER TABLE transactions ADD COLUMN transfer_id BIGINT REFERENCES journal_entries(id);
+CREATE INDEX transactions_transfer_id_idx ON transactions (transfer_id);
@@ -0,0 +1,7 @@
+CREATE TABLE cart_items (
+    employee_id INT NOT NULL REFERENCES employees(id),
+    merch_name TEXT NOT NULL REFERENCES merch_items(name),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (employee_id, merch_name)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE idempotency_keys (
+    employee_id INT NOT NULL REFERENCES employees(id),
+    key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    status_code INT,
+    response_body BYTEA,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (employee_id, key)
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS transactions_employee_id_idx;
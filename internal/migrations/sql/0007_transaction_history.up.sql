@@ -0,0 +1 @@
+CREATE INDEX transactions_employee_id_idx ON transactions (employee_id, created_at DESC, id DESC);
@@ -0,0 +1,13 @@
+ALTER TABLE employees ADD COLUMN role TEXT NOT NULL DEFAULT 'employee'
+    CHECK (role IN ('employee', 'store-manager', 'finance-admin'));
+
+CREATE TABLE role_changes (
+    id SERIAL PRIMARY KEY,
+    employee_id INT NOT NULL REFERENCES employees(id),
+    old_role TEXT NOT NULL,
+    new_role TEXT NOT NULL,
+    changed_by INT NOT NULL REFERENCES employees(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX role_changes_employee_id_idx ON role_changes (employee_id, created_at DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS scheduled_grants;
+ALTER TABLE employees ALTER COLUMN coin_balance SET DEFAULT 1000;
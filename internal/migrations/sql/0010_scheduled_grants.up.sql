@@ -0,0 +1,13 @@
+-- The welcome bonus is credited by the onboarding policy, not by a default.
+ALTER TABLE employees ALTER COLUMN coin_balance SET DEFAULT 0;
+
+CREATE TABLE scheduled_grants (
+    id SERIAL PRIMARY KEY,
+    employee_id INT NOT NULL REFERENCES employees(id),
+    amount INT NOT NULL CHECK (amount > 0),
+    due_at TIMESTAMP NOT NULL,
+    released_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX scheduled_grants_due_idx ON scheduled_grants (due_at) WHERE released_at IS NULL;
@@ -0,0 +1,8 @@
+CREATE TABLE employee_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    employee_id INT NOT NULL REFERENCES employees(id),
+    email TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (issuer, subject)
+);
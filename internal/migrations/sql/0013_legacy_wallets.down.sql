@@ -0,0 +1,2 @@
+-- Opening entries are part of the append-only journal and stay.
+SELECT 1;
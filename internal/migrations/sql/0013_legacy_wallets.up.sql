@@ -0,0 +1,27 @@
+-- Existing employees get a wallet whose opening balance is minted from
+-- their current coin_balance, so that the ledger agrees with it. Purchases
+-- and transfers made before the ledger are not replayed.
+INSERT INTO ledger_accounts (id, kind, employee_id, created_at)
+SELECT 'wallet:' || e.id, 'wallet', e.id, e.created_at
+FROM employees e
+WHERE NOT EXISTS (SELECT 1 FROM ledger_accounts a WHERE a.employee_id = e.id);
+
+DO $$
+DECLARE
+    emp RECORD;
+    opening_entry BIGINT;
+BEGIN
+    FOR emp IN
+        SELECT e.id, e.coin_balance
+        FROM employees e
+        WHERE e.coin_balance > 0
+          AND NOT EXISTS (SELECT 1 FROM ledger_postings p WHERE p.account_id = 'wallet:' || e.id)
+        ORDER BY e.id
+    LOOP
+        INSERT INTO journal_entries (kind) VALUES ('opening') RETURNING id INTO opening_entry;
+        INSERT INTO ledger_postings (entry_id, account_id, amount) VALUES
+            (opening_entry, 'mint:issuance', -emp.coin_balance),
+            (opening_entry, 'wallet:' || emp.id, emp.coin_balance);
+    END LOOP;
+END;
+$$;
@@ -0,0 +1,2 @@
+-- Catalog items may be referenced by carts and edited since; they stay.
+SELECT 1;
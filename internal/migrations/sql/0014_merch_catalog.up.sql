@@ -0,0 +1,13 @@
+-- The catalog formerly compiled into config.MerchPrices.
+INSERT INTO merch_items (name, title, price) VALUES
+    ('t-shirt', 'T-shirt', 80),
+    ('cup', 'Cup', 20),
+    ('book', 'Book', 50),
+    ('pen', 'Pen', 10),
+    ('powerbank', 'Power bank', 200),
+    ('hoody', 'Hoody', 300),
+    ('umbrella', 'Umbrella', 200),
+    ('socks', 'Socks', 10),
+    ('wallet', 'Wallet', 50),
+    ('pink-hoody', 'Pink hoody', 500)
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,2 @@
+UPDATE role_changes SET changed_by = employee_id WHERE changed_by IS NULL;
+ALTER TABLE role_changes ALTER COLUMN changed_by SET NOT NULL;
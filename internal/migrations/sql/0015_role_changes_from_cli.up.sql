@@ -0,0 +1,2 @@
+-- Role changes made with "merch-store promote" have no acting employee.
+ALTER TABLE role_changes ALTER COLUMN changed_by DROP NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE transactions ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
@@ -0,0 +1,3 @@
+-- The history filters take RFC 3339 timestamps with an offset, which a
+-- TIMESTAMP column silently drops. Existing rows were written in UTC.
+ALTER TABLE transactions ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
@@ -0,0 +1,2 @@
+DELETE FROM transactions WHERE transaction_type = 'transfer' AND transfer_id IS NULL AND amount > 0;
+UPDATE transactions SET amount = -amount WHERE transaction_type = 'transfer' AND transfer_id IS NULL;
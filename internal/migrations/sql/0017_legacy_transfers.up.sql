@@ -0,0 +1,14 @@
+-- Before transfers were recorded on both sides, each one was a single row
+-- owned by the sender with a positive amount. Turn that row into the
+-- sender's debit and add the recipient's credit, as TransferCoins records
+-- them now. The rows keep no journal entry: the ledger starts from the
+-- opening balances of migration 13.
+WITH legacy AS (
+    UPDATE transactions SET amount = -amount
+    WHERE transaction_type = 'transfer' AND transfer_id IS NULL AND amount > 0
+    RETURNING employee_id, counterparty_id, amount, created_at
+)
+INSERT INTO transactions (employee_id, counterparty_id, amount, transaction_type, created_at)
+SELECT counterparty_id, employee_id, -amount, 'transfer', created_at
+FROM legacy
+WHERE counterparty_id IS NOT NULL;
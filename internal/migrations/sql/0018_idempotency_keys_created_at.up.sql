@@ -0,0 +1,2 @@
+-- Lets the purge of expired idempotency keys find them without a full scan.
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
@@ -2,10 +2,54 @@ package models
 
 import "time"
 
+const (
+	RoleEmployee     = "employee"
+	RoleStoreManager = "store-manager"
+	RoleFinanceAdmin = "finance-admin"
+)
+
+func ValidRole(role string) bool {
+	switch role {
+	case RoleEmployee, RoleStoreManager, RoleFinanceAdmin:
+		return true
+	}
+	return false
+}
+
 type Employee struct {
-	ID          int       `json:"id"`
-	Username    string    `json:"username"`
-	CoinBalance int       `json:"coin_balance"`
+	ID           int       `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	CoinBalance  int       `json:"coin_balance"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Session is a login of an employee. Every refresh token issued by rotation
+// belongs to the session of the token it replaced.
+type Session struct {
+	ID         string     `json:"id"`
+	EmployeeID int        `json:"employee_id"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type RoleChange struct {
+	ID                int       `json:"id"`
+	EmployeeID        int       `json:"employee_id"`
+	OldRole           string    `json:"old_role"`
+	NewRole           string    `json:"new_role"`
+	ChangedBy         int       `json:"changed_by"`
+	ChangedByUsername string    `json:"changed_by_username"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type MerchItem struct {
+	Name        string    `json:"name"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Price       int       `json:"price"`
+	Active      bool      `json:"active"`
 	CreatedAt   time.Time `json:"created_at"`
 }
 
@@ -18,11 +62,64 @@ type Purchase struct {
 	CreatedAt  time.Time `json:"created_at"`
 }
 
+func (p Purchase) Total() int {
+	return p.Price * p.Quantity
+}
+
+type CartItem struct {
+	MerchName string `json:"merch_name"`
+	Title     string `json:"title"`
+	Price     int    `json:"price"`
+	Quantity  int    `json:"quantity"`
+	Available bool   `json:"available"`
+}
+
+func (i CartItem) Subtotal() int {
+	return i.Price * i.Quantity
+}
+
 type Transaction struct {
-	ID              int       `json:"id"`
-	EmployeeID      int       `json:"employee_id"`
-	CounterpartyID  int       `json:"counterparty_id"`
-	Amount          int       `json:"amount"`
-	TransactionType string    `json:"transaction_type"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID                   int       `json:"id"`
+	EmployeeID           int       `json:"employee_id"`
+	CounterpartyID       int       `json:"counterparty_id"`
+	CounterpartyUsername string    `json:"counterparty_username"`
+	Amount               int       `json:"amount"`
+	TransactionType      string    `json:"transaction_type"`
+	TransferID           *int64    `json:"transfer_id,omitempty"`
+	Reason               string    `json:"reason,omitempty"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+// Grant credits (positive Amount) or claws back (negative Amount) coins of
+// a single employee on behalf of an administrator.
+type Grant struct {
+	EmployeeID int    `json:"employee_id"`
+	Amount     int    `json:"amount"`
+	Reason     string `json:"reason"`
+}
+
+type TransactionCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// TransactionFilter narrows the transaction history of one employee. Zero
+// values mean "no restriction".
+type TransactionFilter struct {
+	Direction    string
+	Counterparty string
+	Type         string
+	From         time.Time
+	To           time.Time
+	After        *TransactionCursor
+	Limit        int
+}
+
+type IdempotencyRecord struct {
+	Key          string    `json:"key"`
+	Fingerprint  string    `json:"fingerprint"`
+	Completed    bool      `json:"completed"`
+	StatusCode   int       `json:"status_code"`
+	ResponseBody []byte    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
 }
@@ -0,0 +1,58 @@
+package onboarding
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const DefaultVestingInterval = time.Minute
+
+// GrantReleaser is implemented by repository.Repository.
+type GrantReleaser interface {
+	ReleaseDueGrants(ctx context.Context, now time.Time) (int, error)
+}
+
+// VestingWorker periodically credits the deferred tranches of the welcome
+// bonus once they are due.
+type VestingWorker struct {
+	releaser GrantReleaser
+	interval time.Duration
+}
+
+func NewVestingWorker(releaser GrantReleaser, interval time.Duration) *VestingWorker {
+	if interval <= 0 {
+		interval = DefaultVestingInterval
+	}
+	return &VestingWorker{releaser: releaser, interval: interval}
+}
+
+// Run releases due grants every interval until ctx is cancelled. Each run
+// keeps releasing batches until nothing is due, so a backlog drains quickly.
+func (w *VestingWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.releaseDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *VestingWorker) releaseDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		released, err := w.releaser.ReleaseDueGrants(ctx, time.Now())
+		if err != nil {
+			log.Printf("failed to release scheduled grants: %v", err)
+			return
+		}
+		if released == 0 {
+			return
+		}
+		log.Printf("released %d scheduled grants", released)
+	}
+}
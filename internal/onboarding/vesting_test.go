@@ -0,0 +1,75 @@
+package onboarding
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeReleaser отдаёт заранее заданные результаты вызовов ReleaseDueGrants
+type fakeReleaser struct {
+	mu      sync.Mutex
+	results []int
+	calls   int
+	err     error
+}
+
+func (f *fakeReleaser) ReleaseDueGrants(ctx context.Context, now time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return 0, f.err
+	}
+	if len(f.results) == 0 {
+		return 0, nil
+	}
+	released := f.results[0]
+	f.results = f.results[1:]
+	return released, nil
+}
+
+func (f *fakeReleaser) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// Воркер выпускает пакеты подряд, пока есть что выпускать
+func TestVestingWorker_DrainsBacklog(t *testing.T) {
+	releaser := &fakeReleaser{results: []int{100, 100, 3}}
+	worker := NewVestingWorker(releaser, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return releaser.Calls() == 4 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+	assert.Equal(t, 4, releaser.Calls(), "после пустого пакета воркер ждёт следующего тика")
+}
+
+func TestVestingWorker_StopsOnError(t *testing.T) {
+	releaser := &fakeReleaser{err: errors.New("db is down")}
+	worker := NewVestingWorker(releaser, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return releaser.Calls() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+	assert.Equal(t, 1, releaser.Calls(), "ошибка не должна приводить к повторам до следующего тика")
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"merch-store/internal/models"
+)
+
+func (r *repositoryImpl) AddToCart(ctx context.Context, employeeID int, merchName string, quantity int) error {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO cart_items (employee_id, merch_name, quantity, added_at)
+		SELECT $1, name, $3, $4 FROM merch_items WHERE name = $2 AND active
+		ON CONFLICT (employee_id, merch_name) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity
+	`, employeeID, merchName, quantity, time.Now())
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrInvalidMerch
+	}
+	return nil
+}
+
+func (r *repositoryImpl) RemoveFromCart(ctx context.Context, employeeID int, merchName string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM cart_items WHERE employee_id = $1 AND merch_name = $2`, employeeID, merchName)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *repositoryImpl) GetCart(ctx context.Context, employeeID int) ([]models.CartItem, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT c.merch_name, m.title, m.price, c.quantity, m.active
+		FROM cart_items c
+		JOIN merch_items m ON m.name = c.merch_name
+		WHERE c.employee_id = $1
+		ORDER BY c.merch_name
+	`, employeeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cart: %w", err)
+	}
+	defer rows.Close()
+
+	var cart []models.CartItem
+	for rows.Next() {
+		var item models.CartItem
+		if err := rows.Scan(&item.MerchName, &item.Title, &item.Price, &item.Quantity, &item.Available); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		cart = append(cart, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return cart, nil
+}
+
+// Checkout buys every line of the cart in one transaction and empties it.
+// Either all lines are purchased or none is.
+func (r *repositoryImpl) Checkout(ctx context.Context, employeeID int) ([]models.Purchase, int, error) {
+	var purchases []models.Purchase
+	var balance int
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		purchases = nil
+
+		rows, err := tx.QueryContext(ctx, `
+			SELECT c.merch_name, m.price, c.quantity, m.active
+			FROM cart_items c
+			JOIN merch_items m ON m.name = c.merch_name
+			WHERE c.employee_id = $1
+			ORDER BY c.merch_name
+			FOR UPDATE OF c
+		`, employeeID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var p models.Purchase
+			var active bool
+			if err := rows.Scan(&p.MerchName, &p.Price, &p.Quantity, &active); err != nil {
+				return err
+			}
+			if !active {
+				return fmt.Errorf("%w: %s", ErrInvalidMerch, p.MerchName)
+			}
+			p.EmployeeID = employeeID
+			purchases = append(purchases, p)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		if len(purchases) == 0 {
+			return ErrEmptyCart
+		}
+
+		balance, err = chargePurchases(ctx, tx, employeeID, purchases)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM cart_items WHERE employee_id = $1`, employeeID)
+		return err
+	})
+	r.recordPurchases(purchases, err)
+	if err != nil {
+		return nil, 0, err
+	}
+	return purchases, balance, nil
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const checkoutCartQuery = `SELECT c.merch_name, m.price, c.quantity, m.active FROM cart_items c JOIN merch_items m ON m.name = c.merch_name WHERE c.employee_id = \$1 ORDER BY c.merch_name FOR UPDATE OF c`
+
+func TestAddToCart_InvalidMerch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectExec(`INSERT INTO cart_items`).
+		WithArgs(1, "yacht", 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.AddToCart(context.Background(), 1, "yacht", 1)
+	assert.ErrorIs(t, err, ErrInvalidMerch)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveFromCart_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM cart_items WHERE employee_id = $1 AND merch_name = $2`)).
+		WithArgs(1, "pen").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.RemoveFromCart(context.Background(), 1, "pen")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckout_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	employeeID := 1
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(checkoutCartQuery).
+		WithArgs(employeeID).
+		WillReturnRows(sqlmock.NewRows([]string{"merch_name", "price", "quantity", "active"}).
+			AddRow("hoody", 300, 1, true).
+			AddRow("socks", 10, 3, true))
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1 FOR UPDATE`).
+		WithArgs(employeeID).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(1000))
+	mock.ExpectQuery(`UPDATE employees SET coin_balance = coin_balance - \$1 WHERE id = \$2 RETURNING coin_balance`).
+		WithArgs(330, employeeID).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(670))
+	mock.ExpectQuery(`INSERT INTO purchases`).
+		WithArgs(employeeID, "hoody", 300, 1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO purchases`).
+		WithArgs(employeeID, "socks", 10, 3, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	expectLedgerMove(mock, "purchase", "wallet:1", "store:revenue", 330, 9)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM cart_items WHERE employee_id = $1`)).
+		WithArgs(employeeID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	purchases, balance, err := repo.Checkout(context.Background(), employeeID)
+	assert.NoError(t, err)
+	assert.Len(t, purchases, 2)
+	assert.Equal(t, 670, balance)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckout_InsufficientFunds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	employeeID := 1
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(checkoutCartQuery).
+		WithArgs(employeeID).
+		WillReturnRows(sqlmock.NewRows([]string{"merch_name", "price", "quantity", "active"}).
+			AddRow("hoody", 300, 1, true).
+			AddRow("socks", 10, 1, true))
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1 FOR UPDATE`).
+		WithArgs(employeeID).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(305))
+	mock.ExpectRollback()
+
+	_, _, err = repo.Checkout(context.Background(), employeeID)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckout_EmptyCart(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(checkoutCartQuery).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"merch_name", "price", "quantity", "active"}))
+	mock.ExpectRollback()
+
+	_, _, err = repo.Checkout(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrEmptyCart)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
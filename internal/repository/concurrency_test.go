@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/migrations"
+)
+
+// Интеграционный тест: требует живой Postgres в TEST_DATABASE_URL
+func TestConcurrentSpending_NeverOverdraws(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := InitDB(dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err = migrator.Up(context.Background()); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	repo := NewRepository(db)
+	suffix := time.Now().UnixNano()
+	wallet, err := repo.CreateEmployee(context.Background(), fmt.Sprintf("hammer-%d", suffix), "x")
+	assert.NoError(t, err)
+	peer, err := repo.CreateEmployee(context.Background(), fmt.Sprintf("hammer-peer-%d", suffix), "x")
+	assert.NoError(t, err)
+
+	const workers, rounds = 50, 20
+	var wg sync.WaitGroup
+	unexpected := make(chan error, workers*rounds)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				var err error
+				// встречные переводы провоцируют взаимоблокировки без упорядочивания блокировок
+				switch (i + j) % 3 {
+				case 0:
+					_, _, err = repo.BuyMerch(context.Background(), wallet.ID, "pen", 1)
+				case 1:
+					err = repo.TransferCoins(context.Background(), wallet.ID, peer.ID, 7)
+				default:
+					err = repo.TransferCoins(context.Background(), peer.ID, wallet.ID, 3)
+				}
+				if err != nil && !errors.Is(err, ErrInsufficientFunds) {
+					unexpected <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(unexpected)
+
+	for err := range unexpected {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, id := range []int{wallet.ID, peer.ID} {
+		emp, err := repo.GetEmployeeByID(context.Background(), id)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, emp.CoinBalance, 0, "баланс не должен уходить в минус")
+		assert.NoError(t, repo.VerifyBalance(context.Background(), id), "баланс должен сходиться с журналом")
+	}
+}
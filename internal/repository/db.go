@@ -1,12 +1,18 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func InitDB(databaseURL string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", databaseURL)
+	db, err := openTraced("postgres", databaseURL)
 	if err != nil {
 		return nil, err
 	}
@@ -16,3 +22,19 @@ func InitDB(databaseURL string) (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+// openTraced opens a pool whose driver starts a span for every SQL statement
+// and transaction, as a child of the span in the statement's context.
+// Statements outside of a trace, such as the readiness checks, are not traced.
+func openTraced(driverName, dataSourceName string) (*sql.DB, error) {
+	return otelsql.Open(driverName, dataSourceName,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}),
+	)
+}
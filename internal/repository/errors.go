@@ -1,9 +1,69 @@
 package repository
 
-import "errors"
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/lib/pq"
+)
 
 var (
 	ErrNotFound          = errors.New("record not found")
 	ErrInsufficientFunds = errors.New("insufficient funds")
 	ErrInvalidMerch      = errors.New("invalid merch name")
+	ErrBalanceMismatch   = errors.New("balance does not match ledger")
+	ErrEmptyCart         = errors.New("cart is empty")
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrTokenExpired      = errors.New("refresh token expired")
+	ErrTokenReused       = errors.New("refresh token reused")
+	ErrSessionRevoked    = errors.New("session revoked")
+	ErrAlreadyExists     = errors.New("record already exists")
 )
+
+// notFound translates sql.ErrNoRows so that callers never depend on
+// database/sql.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// alreadyExists translates a unique_violation, such as a second employee
+// registered under a taken username.
+func alreadyExists(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+// IsUnavailable reports whether err means the database cannot be reached or
+// is refusing work for now, as opposed to a failed query. Such requests may
+// succeed when retried later.
+func IsUnavailable(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code.Class() == "08": // connection_exception
+			return true
+		case pqErr.Code == "53300", // too_many_connections
+			pqErr.Code == "57P01", // admin_shutdown
+			pqErr.Code == "57P02", // crash_shutdown
+			pqErr.Code == "57P03": // cannot_connect_now
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	unavailable := []error{
+		driver.ErrBadConn,
+		sql.ErrConnDone,
+		fmt.Errorf("query: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}),
+		&pq.Error{Code: "08006"},
+		&pq.Error{Code: "57P01"},
+		&pq.Error{Code: "53300"},
+	}
+	for _, err := range unavailable {
+		assert.True(t, IsUnavailable(err), "ошибка %v означает недоступность базы", err)
+	}
+
+	available := []error{
+		ErrNotFound,
+		sql.ErrNoRows,
+		&pq.Error{Code: "23505"},
+		errors.New("syntax error"),
+	}
+	for _, err := range available {
+		assert.False(t, IsUnavailable(err), "ошибка %v не означает недоступность базы", err)
+	}
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"merch-store/internal/ledger"
+	"merch-store/internal/models"
+)
+
+const (
+	TransactionTypeTransfer = "transfer"
+	TransactionTypeGrant    = "grant"
+	TransactionTypeClawback = "clawback"
+	TransactionTypeWelcome  = "welcome"
+	TransactionTypeVesting  = "vesting"
+)
+
+// GrantCoins mints coins into (or claws them back from) the wallets of one or
+// more employees on behalf of actorID. All grants are applied in a single
+// transaction: if any employee is missing or a clawback would overdraw a
+// wallet, nothing is changed.
+func (r *repositoryImpl) GrantCoins(ctx context.Context, actorID int, grants []models.Grant) ([]models.Transaction, error) {
+	ids := make([]int, 0, len(grants))
+	for _, g := range grants {
+		ids = append(ids, g.EmployeeID)
+	}
+
+	var transactions []models.Transaction
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		transactions = make([]models.Transaction, 0, len(grants))
+
+		balances, err := lockWallets(ctx, tx, ids...)
+		if err != nil {
+			return err
+		}
+		for _, g := range grants {
+			balances[g.EmployeeID] += g.Amount
+			if balances[g.EmployeeID] < 0 {
+				return ErrInsufficientFunds
+			}
+		}
+
+		now := time.Now()
+		for _, g := range grants {
+			txType := TransactionTypeGrant
+			entry := ledger.Move(txType, ledger.MintAccount, ledger.WalletAccount(g.EmployeeID), g.Amount)
+			if g.Amount < 0 {
+				txType = TransactionTypeClawback
+				entry = ledger.Move(txType, ledger.WalletAccount(g.EmployeeID), ledger.MintAccount, -g.Amount)
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE employees SET coin_balance = coin_balance + $1 WHERE id = $2`, g.Amount, g.EmployeeID)
+			if err != nil {
+				return err
+			}
+
+			var entryID int64
+			entryID, err = ledger.Post(ctx, tx, entry)
+			if err != nil {
+				return err
+			}
+
+			t := models.Transaction{
+				EmployeeID:      g.EmployeeID,
+				CounterpartyID:  actorID,
+				Amount:          g.Amount,
+				TransactionType: txType,
+				TransferID:      &entryID,
+				Reason:          g.Reason,
+				CreatedAt:       now,
+			}
+			err = tx.QueryRowContext(ctx,
+				`INSERT INTO transactions (employee_id, counterparty_id, amount, transaction_type, transfer_id, reason, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+				t.EmployeeID, t.CounterpartyID, t.Amount, t.TransactionType, entryID, t.Reason, now,
+			).Scan(&t.ID)
+			if err != nil {
+				return err
+			}
+			transactions = append(transactions, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
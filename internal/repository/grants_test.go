@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/models"
+)
+
+func TestGrantCoins_GrantAndClawback(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	adminID := 9
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1 FOR UPDATE`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(100))
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1 FOR UPDATE`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(100))
+
+	updateBalance := regexp.QuoteMeta(`UPDATE employees SET coin_balance = coin_balance + $1 WHERE id = $2`)
+	insertTx := regexp.QuoteMeta(`INSERT INTO transactions (employee_id, counterparty_id, amount, transaction_type, transfer_id, reason, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`)
+
+	// Начисление: монеты выпускаются из mint в кошелёк
+	mock.ExpectExec(updateBalance).WithArgs(500, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLedgerMove(mock, "grant", "mint:issuance", "wallet:3", 500, 11)
+	mock.ExpectQuery(insertTx).
+		WithArgs(3, adminID, 500, "grant", int64(11), "quarterly bonus", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+
+	// Списание: монеты возвращаются из кошелька в mint
+	mock.ExpectExec(updateBalance).WithArgs(-40, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLedgerMove(mock, "clawback", "wallet:2", "mint:issuance", 40, 12)
+	mock.ExpectQuery(insertTx).
+		WithArgs(2, adminID, -40, "clawback", int64(12), "quarterly bonus", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(22))
+	mock.ExpectCommit()
+
+	transactions, err := repo.GrantCoins(context.Background(), adminID, []models.Grant{
+		{EmployeeID: 3, Amount: 500, Reason: "quarterly bonus"},
+		{EmployeeID: 2, Amount: -40, Reason: "quarterly bonus"},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, transactions, 2) {
+		assert.Equal(t, TransactionTypeGrant, transactions[0].TransactionType)
+		assert.Equal(t, adminID, transactions[0].CounterpartyID, "начисление приписано администратору")
+		assert.Equal(t, TransactionTypeClawback, transactions[1].TransactionType)
+		assert.Equal(t, -40, transactions[1].Amount)
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Одно невыполнимое списание откатывает весь пакет
+func TestGrantCoins_ClawbackExceedsBalance(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1 FOR UPDATE`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(100))
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1 FOR UPDATE`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(30))
+	mock.ExpectRollback()
+
+	_, err = repo.GrantCoins(context.Background(), 9, []models.Grant{
+		{EmployeeID: 2, Amount: 100, Reason: "fix"},
+		{EmployeeID: 3, Amount: -50, Reason: "fix"},
+	})
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGrantCoins_UnknownEmployee(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1 FOR UPDATE`).
+		WithArgs(42).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err = repo.GrantCoins(context.Background(), 9, []models.Grant{{EmployeeID: 42, Amount: 10, Reason: "bonus"}})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
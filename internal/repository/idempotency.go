@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"merch-store/internal/models"
+)
+
+// purgeBatchSize bounds the number of idempotency keys deleted by a single
+// PurgeIdempotencyKeys call.
+const purgeBatchSize = 1000
+
+// ReserveIdempotencyKey claims key for the employee. When the key is already
+// taken by a request younger than retention, the stored record is returned and
+// reserved is false; expired records are discarded and the key is claimed anew.
+func (r *repositoryImpl) ReserveIdempotencyKey(ctx context.Context, employeeID int, key, fingerprint string, retention time.Duration) (models.IdempotencyRecord, bool, error) {
+	var record models.IdempotencyRecord
+	reserved := false
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+		_, err := tx.ExecContext(ctx,
+			`DELETE FROM idempotency_keys WHERE employee_id = $1 AND key = $2 AND created_at < $3`,
+			employeeID, key, now.Add(-retention),
+		)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO idempotency_keys (employee_id, key, fingerprint, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (employee_id, key) DO NOTHING`,
+			employeeID, key, fingerprint, now,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 1 {
+			reserved = true
+			record = models.IdempotencyRecord{Key: key, Fingerprint: fingerprint, CreatedAt: now}
+			return nil
+		}
+
+		var status sql.NullInt64
+		err = tx.QueryRowContext(ctx,
+			`SELECT key, fingerprint, status_code, response_body, created_at FROM idempotency_keys WHERE employee_id = $1 AND key = $2`,
+			employeeID, key,
+		).Scan(&record.Key, &record.Fingerprint, &status, &record.ResponseBody, &record.CreatedAt)
+		if err != nil {
+			return err
+		}
+		record.Completed = status.Valid
+		record.StatusCode = int(status.Int64)
+		return nil
+	})
+	return record, reserved, err
+}
+
+func (r *repositoryImpl) CompleteIdempotencyKey(ctx context.Context, employeeID int, key string, statusCode int, responseBody []byte) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET status_code = $3, response_body = $4 WHERE employee_id = $1 AND key = $2`,
+		employeeID, key, statusCode, responseBody,
+	)
+	return err
+}
+
+func (r *repositoryImpl) ReleaseIdempotencyKey(ctx context.Context, employeeID int, key string) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE employee_id = $1 AND key = $2 AND status_code IS NULL`,
+		employeeID, key,
+	)
+	return err
+}
+
+// PurgeIdempotencyKeys deletes up to a batch of keys created before the given
+// time, stored responses included, and returns how many it deleted.
+func (r *repositoryImpl) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE (employee_id, key) IN (
+			SELECT employee_id, key FROM idempotency_keys WHERE created_at < $1 LIMIT $2
+		)
+	`, before, purgeBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := res.RowsAffected()
+	return int(purged), err
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserveIdempotencyKey_New(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM idempotency_keys WHERE employee_id = $1 AND key = $2 AND created_at < $3`)).
+		WithArgs(1, "key-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO idempotency_keys (employee_id, key, fingerprint, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (employee_id, key) DO NOTHING`)).
+		WithArgs(1, "key-1", "fp", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	record, reserved, err := repo.ReserveIdempotencyKey(context.Background(), 1, "key-1", "fp", time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Equal(t, "fp", record.Fingerprint)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReserveIdempotencyKey_Existing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM idempotency_keys`).
+		WithArgs(1, "key-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO idempotency_keys`).
+		WithArgs(1, "key-1", "fp", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT key, fingerprint, status_code, response_body, created_at FROM idempotency_keys WHERE employee_id = $1 AND key = $2`)).
+		WithArgs(1, "key-1").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "fingerprint", "status_code", "response_body", "created_at"}).
+			AddRow("key-1", "fp", 200, []byte(`{"message":"ok"}`), time.Now()))
+	mock.ExpectCommit()
+
+	record, reserved, err := repo.ReserveIdempotencyKey(context.Background(), 1, "key-1", "fp", time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	assert.True(t, record.Completed)
+	assert.Equal(t, 200, record.StatusCode)
+	assert.Equal(t, `{"message":"ok"}`, string(record.ResponseBody))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeIdempotencyKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	before := time.Now().Add(-24 * time.Hour)
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM idempotency_keys WHERE (employee_id, key) IN ( SELECT employee_id, key FROM idempotency_keys WHERE created_at < $1 LIMIT $2 )`)).
+		WithArgs(before, purgeBatchSize).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	purged, err := repo.PurgeIdempotencyKeys(context.Background(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, purged)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"merch-store/internal/models"
+)
+
+// GetEmployeeByIdentity finds the employee linked to an external identity,
+// identified by the issuer of the identity provider and the subject it
+// assigned to the user.
+func (r *repositoryImpl) GetEmployeeByIdentity(ctx context.Context, issuer, subject string) (models.Employee, error) {
+	var emp models.Employee
+	err := r.db.QueryRowContext(ctx, `
+		SELECT e.id, e.username, e.password_hash, e.role, e.coin_balance, e.created_at
+		FROM employee_identities i
+		JOIN employees e ON e.id = i.employee_id
+		WHERE i.issuer = $1 AND i.subject = $2
+	`, issuer, subject).Scan(&emp.ID, &emp.Username, &emp.PasswordHash, &emp.Role, &emp.CoinBalance, &emp.CreatedAt)
+	if err != nil {
+		return emp, notFound(err)
+	}
+	return emp, nil
+}
+
+// LinkIdentity links an external identity to an employee. An identity that is
+// already linked keeps its employee. Linking makes the account SSO-only: its
+// password is cleared and its sessions are revoked, so that whoever may have
+// registered the username before its owner cannot keep using it.
+func (r *repositoryImpl) LinkIdentity(ctx context.Context, employeeID int, issuer, subject, email string) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO employee_identities (issuer, subject, employee_id, email, created_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (issuer, subject) DO NOTHING`,
+			issuer, subject, employeeID, email, now,
+		)
+		if err != nil {
+			return err
+		}
+		linked, err := res.RowsAffected()
+		if err != nil || linked == 0 {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE employees SET password_hash = '' WHERE id = $1`, employeeID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE auth_sessions SET revoked_at = $1 WHERE employee_id = $2 AND revoked_at IS NULL`,
+			now, employeeID,
+		)
+		return err
+	})
+}
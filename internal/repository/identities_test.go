@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEmployeeByIdentity_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectQuery(`SELECT e.id, e.username, e.password_hash, e.role, e.coin_balance, e.created_at\s+FROM employee_identities i`).
+		WithArgs("https://idp.example.com", "sub-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "coin_balance", "created_at"}))
+
+	_, err = repo.GetEmployeeByIdentity(context.Background(), "https://idp.example.com", "sub-1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Привязка внешней учётной записи отключает вход по паролю и отзывает сессии
+func TestLinkIdentity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO employee_identities (issuer, subject, employee_id, email, created_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (issuer, subject) DO NOTHING`)).
+		WithArgs("https://idp.example.com", "sub-1", 7, "alice@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE employees SET password_hash = '' WHERE id = $1`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE auth_sessions SET revoked_at = $1 WHERE employee_id = $2 AND revoked_at IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err = repo.LinkIdentity(context.Background(), 7, "https://idp.example.com", "sub-1", "alice@example.com")
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLinkIdentity_AlreadyLinked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO employee_identities`)).
+		WithArgs("https://idp.example.com", "sub-1", 7, "alice@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err = repo.LinkIdentity(context.Background(), 7, "https://idp.example.com", "sub-1", "alice@example.com")
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
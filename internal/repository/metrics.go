@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"errors"
+
+	"merch-store/internal/models"
+)
+
+// Reasons a purchase or checkout fails, as reported to Metrics.
+const (
+	FailureInsufficientFunds = "insufficient_funds"
+	FailureInvalidMerch      = "invalid_merch"
+	FailureEmptyCart         = "empty_cart"
+	FailureUnavailable       = "unavailable"
+	FailureOther             = "error"
+)
+
+// Metrics records business events. It is implemented by *metrics.Metrics.
+// Events are recorded once their transaction has committed, so retried
+// transactions are not counted twice.
+type Metrics interface {
+	CoinsTransferred(amount int)
+	CoinsSpent(item string, amount int)
+	PurchaseFailed(reason string)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) CoinsTransferred(amount int)        {}
+func (noopMetrics) CoinsSpent(item string, amount int) {}
+func (noopMetrics) PurchaseFailed(reason string)       {}
+
+// WithMetrics makes the repository record business events.
+func WithMetrics(metrics Metrics) Option {
+	return func(r *repositoryImpl) {
+		r.metrics = metrics
+	}
+}
+
+// recordPurchases reports the outcome of BuyMerch or Checkout.
+func (r *repositoryImpl) recordPurchases(purchases []models.Purchase, err error) {
+	if err != nil {
+		r.metrics.PurchaseFailed(purchaseFailureReason(err))
+		return
+	}
+	for _, p := range purchases {
+		r.metrics.CoinsSpent(p.MerchName, p.Total())
+	}
+}
+
+func purchaseFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrInsufficientFunds):
+		return FailureInsufficientFunds
+	case errors.Is(err, ErrInvalidMerch):
+		return FailureInvalidMerch
+	case errors.Is(err, ErrEmptyCart):
+		return FailureEmptyCart
+	case IsUnavailable(err):
+		return FailureUnavailable
+	default:
+		return FailureOther
+	}
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMetrics запоминает события, переданные репозиторием
+type recordingMetrics struct {
+	transferred int
+	spent       map[string]int
+	failures    []string
+}
+
+func (m *recordingMetrics) CoinsTransferred(amount int) { m.transferred += amount }
+func (m *recordingMetrics) CoinsSpent(item string, amount int) {
+	if m.spent == nil {
+		m.spent = map[string]int{}
+	}
+	m.spent[item] += amount
+}
+func (m *recordingMetrics) PurchaseFailed(reason string) { m.failures = append(m.failures, reason) }
+
+func TestPurchaseFailureReason(t *testing.T) {
+	cases := map[error]string{
+		ErrInsufficientFunds:                      FailureInsufficientFunds,
+		fmt.Errorf("%w: socks", ErrInvalidMerch):  FailureInvalidMerch,
+		ErrEmptyCart:                              FailureEmptyCart,
+		driver.ErrBadConn:                         FailureUnavailable,
+		errors.New("pq: relation does not exist"): FailureOther,
+	}
+	for err, reason := range cases {
+		assert.Equal(t, reason, purchaseFailureReason(err), "ошибка %v", err)
+	}
+}
+
+func TestBuyMerch_RecordsMetrics(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	metrics := &recordingMetrics{}
+	repo := NewRepository(db, WithMetrics(metrics))
+
+	priceQuery := regexp.QuoteMeta(`SELECT price FROM merch_items WHERE name = $1 AND active`)
+	mock.ExpectBegin()
+	mock.ExpectQuery(priceQuery).
+		WithArgs("yacht").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(priceQuery).
+		WithArgs("pen").
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(10))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT coin_balance FROM employees WHERE id = $1 FOR UPDATE`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(5))
+	mock.ExpectRollback()
+
+	_, _, err = repo.BuyMerch(context.Background(), 1, "yacht", 1)
+	assert.ErrorIs(t, err, ErrInvalidMerch)
+	_, _, err = repo.BuyMerch(context.Background(), 1, "pen", 1)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	assert.Equal(t, []string{FailureInvalidMerch, FailureInsufficientFunds}, metrics.failures)
+	assert.Empty(t, metrics.spent, "неудачные покупки не считаются потраченными монетами")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
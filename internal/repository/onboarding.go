@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"merch-store/internal/ledger"
+)
+
+// releaseBatchSize bounds the number of scheduled grants released by a
+// single ReleaseDueGrants call so that one run never holds locks for long.
+const releaseBatchSize = 100
+
+// recordMint posts a mint entry for coins already added to the employee
+// balance and records it as a visible transaction without a counterparty.
+func recordMint(ctx context.Context, tx *sql.Tx, employeeID, amount int, txType string, now time.Time) error {
+	entryID, err := ledger.Post(ctx, tx, ledger.Move(txType, ledger.MintAccount, ledger.WalletAccount(employeeID), amount))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO transactions (employee_id, amount, transaction_type, transfer_id, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		employeeID, amount, txType, entryID, now,
+	)
+	return err
+}
+
+// ReleaseDueGrants credits the vesting tranches of the welcome bonus that are
+// due at now and returns how many were released. Rows locked by another
+// replica are skipped, so it is safe to run on every instance. Grants are
+// processed in employee id order, which keeps wallet row locks ascending as
+// in lockWallets.
+func (r *repositoryImpl) ReleaseDueGrants(ctx context.Context, now time.Time) (int, error) {
+	released := 0
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		released = 0
+
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id, employee_id, amount
+			FROM scheduled_grants
+			WHERE released_at IS NULL AND due_at <= $1
+			ORDER BY employee_id, id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		`, now, releaseBatchSize)
+		if err != nil {
+			return err
+		}
+
+		type dueGrant struct {
+			id, employeeID, amount int
+		}
+		var due []dueGrant
+		for rows.Next() {
+			var g dueGrant
+			if err = rows.Scan(&g.id, &g.employeeID, &g.amount); err != nil {
+				rows.Close()
+				return err
+			}
+			due = append(due, g)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for _, g := range due {
+			_, err = tx.ExecContext(ctx, `UPDATE employees SET coin_balance = coin_balance + $1 WHERE id = $2`, g.amount, g.employeeID)
+			if err != nil {
+				return err
+			}
+			err = recordMint(ctx, tx, g.employeeID, g.amount, TransactionTypeVesting, now)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `UPDATE scheduled_grants SET released_at = $1 WHERE id = $2`, now, g.id)
+			if err != nil {
+				return err
+			}
+		}
+		released = len(due)
+		return nil
+	})
+	return released, err
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/config"
+)
+
+func TestCreateEmployee_VestingSchedule(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	policy, err := config.ParseOnboardingPolicy("500,500@720h")
+	assert.NoError(t, err)
+	repo := NewRepository(db, WithOnboardingPolicy(policy))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO employees (username, password_hash, coin_balance, created_at) VALUES ($1, $2, $3, $4) RETURNING id, username, password_hash, role, coin_balance, created_at`)).
+		WithArgs("alice", "hash", 500, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "coin_balance", "created_at"}).
+			AddRow(1, "alice", "hash", "employee", 500, time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO ledger_accounts (id, kind, employee_id, created_at) VALUES ($1, $2, $3, $4)`)).
+		WithArgs("wallet:1", "wallet", 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLedgerMove(mock, "welcome", "mint:issuance", "wallet:1", 500, 1)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO transactions (employee_id, amount, transaction_type, transfer_id, created_at) VALUES ($1, $2, $3, $4, $5)`)).
+		WithArgs(1, 500, "welcome", int64(1), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO scheduled_grants (employee_id, amount, due_at, created_at) VALUES ($1, $2, $3, $4)`)).
+		WithArgs(1, 500, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	emp, err := repo.CreateEmployee(context.Background(), "alice", "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, 500, emp.CoinBalance)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Без приветственного бонуса не создаётся ни проводок, ни транзакций
+func TestCreateEmployee_NoWelcomeBonus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db, WithOnboardingPolicy(config.OnboardingPolicy{}))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO employees (username, password_hash, coin_balance, created_at) VALUES ($1, $2, $3, $4) RETURNING id, username, password_hash, role, coin_balance, created_at`)).
+		WithArgs("alice", "hash", 0, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "coin_balance", "created_at"}).
+			AddRow(1, "alice", "hash", "employee", 0, time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO ledger_accounts (id, kind, employee_id, created_at) VALUES ($1, $2, $3, $4)`)).
+		WithArgs("wallet:1", "wallet", 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err = repo.CreateEmployee(context.Background(), "alice", "hash")
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReleaseDueGrants(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, employee_id, amount\s+FROM scheduled_grants\s+WHERE released_at IS NULL AND due_at <= \$1`).
+		WithArgs(now, releaseBatchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "employee_id", "amount"}).AddRow(7, 1, 500))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE employees SET coin_balance = coin_balance + $1 WHERE id = $2`)).
+		WithArgs(500, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLedgerMove(mock, "vesting", "mint:issuance", "wallet:1", 500, 3)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO transactions (employee_id, amount, transaction_type, transfer_id, created_at) VALUES ($1, $2, $3, $4, $5)`)).
+		WithArgs(1, 500, "vesting", int64(3), now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE scheduled_grants SET released_at = $1 WHERE id = $2`)).
+		WithArgs(now, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	released, err := repo.ReleaseDueGrants(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, released)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
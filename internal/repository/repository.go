@@ -1,13 +1,40 @@
 package repository
 
-import "merch-store/internal/models"
+import (
+	"context"
+	"merch-store/internal/models"
+	"time"
+)
 
 type Repository interface {
-	CreateEmployee(username string) (models.Employee, error)
-	GetEmployeeByID(id int) (models.Employee, error)
-	GetEmployeeByUsername(username string) (models.Employee, error)
-	BuyMerch(employeeID int, merchName string, quantity int) error
-	TransferCoins(fromID, toID, amount int) error
-	GetWalletInfo(employeeID int) (int, []models.Transaction, error)
-	GetInventory(employeeID int) ([]map[string]interface{}, error)
+	CreateEmployee(ctx context.Context, username, passwordHash string) (models.Employee, error)
+	SetInitialPassword(ctx context.Context, employeeID int, passwordHash string) (bool, error)
+	GetEmployeeByID(ctx context.Context, id int) (models.Employee, error)
+	GetEmployeeByUsername(ctx context.Context, username string) (models.Employee, error)
+	GetEmployeeByIdentity(ctx context.Context, issuer, subject string) (models.Employee, error)
+	LinkIdentity(ctx context.Context, employeeID int, issuer, subject, email string) error
+	CreateSession(ctx context.Context, employeeID int, sessionID, refreshTokenHash string, expiresAt time.Time) error
+	RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string, expiresAt time.Time) (models.Session, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	SessionRevoked(ctx context.Context, sessionID string) (bool, error)
+	SetEmployeeRole(ctx context.Context, actorID, employeeID int, role string) error
+	ListRoleChanges(ctx context.Context, employeeID int) ([]models.RoleChange, error)
+	ListMerchItems(ctx context.Context) ([]models.MerchItem, error)
+	GetMerchItem(ctx context.Context, name string) (models.MerchItem, error)
+	BuyMerch(ctx context.Context, employeeID int, merchName string, quantity int) (models.Purchase, int, error)
+	AddToCart(ctx context.Context, employeeID int, merchName string, quantity int) error
+	RemoveFromCart(ctx context.Context, employeeID int, merchName string) error
+	GetCart(ctx context.Context, employeeID int) ([]models.CartItem, error)
+	Checkout(ctx context.Context, employeeID int) ([]models.Purchase, int, error)
+	TransferCoins(ctx context.Context, fromID, toID, amount int) error
+	GrantCoins(ctx context.Context, actorID int, grants []models.Grant) ([]models.Transaction, error)
+	ReleaseDueGrants(ctx context.Context, now time.Time) (int, error)
+	GetWalletInfo(ctx context.Context, employeeID int) (int, []models.Transaction, error)
+	ListTransactions(ctx context.Context, employeeID int, filter models.TransactionFilter) ([]models.Transaction, error)
+	GetInventory(ctx context.Context, employeeID int) ([]map[string]interface{}, error)
+	VerifyBalance(ctx context.Context, employeeID int) error
+	ReserveIdempotencyKey(ctx context.Context, employeeID int, key, fingerprint string, retention time.Duration) (models.IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, employeeID int, key string, statusCode int, responseBody []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, employeeID int, key string) error
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error)
 }
@@ -1,151 +1,303 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"merch-store/internal/config"
+	"merch-store/internal/ledger"
 	"merch-store/internal/models"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// recentTransactionsLimit caps the history returned by GetWalletInfo;
+	// ListTransactions pages through the rest.
+	recentTransactionsLimit = 20
 )
 
 type repositoryImpl struct {
-	db *sql.DB
+	db             *sql.DB
+	onboarding     config.OnboardingPolicy
+	metrics        Metrics
+	tracerProvider trace.TracerProvider
 }
 
-func NewRepository(db *sql.DB) Repository {
-	return &repositoryImpl{db: db}
-}
+// Option configures optional behaviour of the repository.
+type Option func(*repositoryImpl)
 
-func (r *repositoryImpl) CreateEmployee(username string) (models.Employee, error) {
-	var emp models.Employee
-	err := r.db.QueryRow(
-		`INSERT INTO employees (username, coin_balance, created_at) VALUES ($1, $2, $3) RETURNING id, username, coin_balance, created_at`,
-		username, 1000, time.Now(),
-	).Scan(&emp.ID, &emp.Username, &emp.CoinBalance, &emp.CreatedAt)
-	if err != nil {
-		return emp, err
+// WithOnboardingPolicy sets the welcome bonus credited to new employees.
+// Without it every employee gets config.DefaultOnboardingPolicy.
+func WithOnboardingPolicy(policy config.OnboardingPolicy) Option {
+	return func(r *repositoryImpl) {
+		r.onboarding = policy
 	}
-	return emp, nil
 }
 
-func (r *repositoryImpl) BuyMerch(employeeID int, merchName string, quantity int) error {
-	price, ok := config.MerchPrices[merchName]
-	if !ok {
-		return ErrInvalidMerch
+func NewRepository(db *sql.DB, opts ...Option) Repository {
+	r := &repositoryImpl{db: db, onboarding: config.DefaultOnboardingPolicy(), metrics: noopMetrics{}}
+	for _, opt := range opts {
+		opt(r)
 	}
-	totalCost := price * quantity
+	return newTracedRepository(r, r.tracerProvider)
+}
+
+// CreateEmployee registers an employee and credits the welcome bonus of the
+// onboarding policy: the immediate part as a "welcome" transaction, the
+// deferred tranches as scheduled grants released by ReleaseDueGrants.
+func (r *repositoryImpl) CreateEmployee(ctx context.Context, username, passwordHash string) (models.Employee, error) {
+	var emp models.Employee
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+		bonus := r.onboarding.Immediate()
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO employees (username, password_hash, coin_balance, created_at) VALUES ($1, $2, $3, $4) RETURNING id, username, password_hash, role, coin_balance, created_at`,
+			username, passwordHash, bonus, now,
+		).Scan(&emp.ID, &emp.Username, &emp.PasswordHash, &emp.Role, &emp.CoinBalance, &emp.CreatedAt)
+		if err != nil {
+			return alreadyExists(err)
+		}
 
-	tx, err := r.db.Begin()
+		err = ledger.OpenWallet(ctx, tx, emp.ID)
+		if err != nil {
+			return err
+		}
+		if bonus > 0 {
+			err = recordMint(ctx, tx, emp.ID, bonus, TransactionTypeWelcome, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, tranche := range r.onboarding.Deferred() {
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO scheduled_grants (employee_id, amount, due_at, created_at) VALUES ($1, $2, $3, $4)`,
+				emp.ID, tranche.Amount, now.Add(tranche.After), now,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return emp, err
+}
+
+// SetInitialPassword sets the password of an employee registered before
+// passwords existed and reports whether it did. Employees that already have a
+// password, or that are linked to an SSO identity and so must not get one,
+// are left alone.
+func (r *repositoryImpl) SetInitialPassword(ctx context.Context, employeeID int, passwordHash string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE employees SET password_hash = $1
+		WHERE id = $2 AND password_hash = ''
+			AND NOT EXISTS (SELECT 1 FROM employee_identities WHERE employee_id = $2)
+	`, passwordHash, employeeID)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer tx.Rollback()
+	updated, err := res.RowsAffected()
+	return updated == 1, err
+}
 
-	var balance int
-	err = tx.QueryRow(`SELECT coin_balance FROM employees WHERE id = $1`, employeeID).Scan(&balance)
+// ListMerchItems returns the whole catalog, including items taken off sale,
+// which have Active unset.
+func (r *repositoryImpl) ListMerchItems(ctx context.Context) ([]models.MerchItem, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT name, title, description, price, active, created_at
+		FROM merch_items
+		ORDER BY name
+	`)
 	if err != nil {
-		return err
-	}
-	if balance < totalCost {
-		return ErrInsufficientFunds
+		return nil, fmt.Errorf("failed to query merch items: %w", err)
 	}
+	defer rows.Close()
 
-	_, err = tx.Exec(`UPDATE employees SET coin_balance = coin_balance - $1 WHERE id = $2`, totalCost, employeeID)
-	if err != nil {
-		return err
+	var items []models.MerchItem
+	for rows.Next() {
+		var item models.MerchItem
+		if err := rows.Scan(&item.Name, &item.Title, &item.Description, &item.Price, &item.Active, &item.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		items = append(items, item)
 	}
 
-	_, err = tx.Exec(
-		`INSERT INTO purchases (employee_id, merch_name, price, quantity, created_at) VALUES ($1, $2, $3, $4, $5)`,
-		employeeID, merchName, price, quantity, time.Now(),
-	)
-	if err != nil {
-		return err
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
 	}
 
-	return tx.Commit()
+	return items, nil
 }
 
-func (r *repositoryImpl) TransferCoins(fromID, toID, amount int) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
+// GetMerchItem returns the item with the given name, including an item taken
+// off sale. An unknown name is ErrInvalidMerch.
+func (r *repositoryImpl) GetMerchItem(ctx context.Context, name string) (models.MerchItem, error) {
+	var item models.MerchItem
+	err := r.db.QueryRowContext(ctx,
+		`SELECT name, title, description, price, active, created_at FROM merch_items WHERE name = $1`,
+		name,
+	).Scan(&item.Name, &item.Title, &item.Description, &item.Price, &item.Active, &item.CreatedAt)
+	if err == sql.ErrNoRows {
+		return item, ErrInvalidMerch
 	}
-	defer tx.Rollback()
+	return item, err
+}
 
-	var fromBalance int
-	err = tx.QueryRow(`SELECT coin_balance FROM employees WHERE id = $1`, fromID).Scan(&fromBalance)
-	if err != nil {
+func (r *repositoryImpl) BuyMerch(ctx context.Context, employeeID int, merchName string, quantity int) (models.Purchase, int, error) {
+	var purchases []models.Purchase
+	var balance int
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var price int
+		err := tx.QueryRowContext(ctx, `SELECT price FROM merch_items WHERE name = $1 AND active`, merchName).Scan(&price)
+		if err == sql.ErrNoRows {
+			return ErrInvalidMerch
+		}
+		if err != nil {
+			return err
+		}
+
+		purchases = []models.Purchase{{
+			EmployeeID: employeeID,
+			MerchName:  merchName,
+			Price:      price,
+			Quantity:   quantity,
+		}}
+		balance, err = chargePurchases(ctx, tx, employeeID, purchases)
 		return err
+	})
+	r.recordPurchases(purchases, err)
+	if err != nil {
+		return models.Purchase{}, 0, err
 	}
-	if fromBalance < amount {
-		return ErrInsufficientFunds
+	return purchases[0], balance, nil
+}
+
+// chargePurchases debits the total cost of purchases from the employee wallet
+// and records them. It fills in the ID and CreatedAt of every purchase and
+// returns the new balance.
+func chargePurchases(ctx context.Context, tx *sql.Tx, employeeID int, purchases []models.Purchase) (int, error) {
+	totalCost := 0
+	for _, p := range purchases {
+		totalCost += p.Total()
 	}
 
-	var toBalance int
-	err = tx.QueryRow(`SELECT coin_balance FROM employees WHERE id = $1`, toID).Scan(&toBalance)
+	balances, err := lockWallets(ctx, tx, employeeID)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if balances[employeeID] < totalCost {
+		return 0, ErrInsufficientFunds
 	}
 
-	_, err = tx.Exec(`UPDATE employees SET coin_balance = coin_balance - $1 WHERE id = $2`, amount, fromID)
+	var balance int
+	err = tx.QueryRowContext(ctx,
+		`UPDATE employees SET coin_balance = coin_balance - $1 WHERE id = $2 RETURNING coin_balance`,
+		totalCost, employeeID,
+	).Scan(&balance)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	_, err = tx.Exec(`UPDATE employees SET coin_balance = coin_balance + $1 WHERE id = $2`, amount, toID)
-	if err != nil {
-		return err
+
+	now := time.Now()
+	for i := range purchases {
+		purchases[i].CreatedAt = now
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO purchases (employee_id, merch_name, price, quantity, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+			employeeID, purchases[i].MerchName, purchases[i].Price, purchases[i].Quantity, now,
+		).Scan(&purchases[i].ID)
+		if err != nil {
+			return 0, err
+		}
 	}
 
-	_, err = tx.Exec(
-		`INSERT INTO transactions (employee_id, counterparty_id, amount, transaction_type, created_at) VALUES ($1, $2, $3, $4, $5)`,
-		fromID, toID, amount, "transfer", time.Now(),
-	)
+	_, err = ledger.Post(ctx, tx, ledger.Move("purchase", ledger.WalletAccount(employeeID), ledger.RevenueAccount, totalCost))
 	if err != nil {
-		return err
+		return 0, err
 	}
+	return balance, nil
+}
 
-	return tx.Commit()
+func (r *repositoryImpl) TransferCoins(ctx context.Context, fromID, toID, amount int) error {
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		balances, err := lockWallets(ctx, tx, fromID, toID)
+		if err != nil {
+			return err
+		}
+		if balances[fromID] < amount {
+			return ErrInsufficientFunds
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE employees SET coin_balance = coin_balance - $1 WHERE id = $2`, amount, fromID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE employees SET coin_balance = coin_balance + $1 WHERE id = $2`, amount, toID)
+		if err != nil {
+			return err
+		}
+
+		transferID, err := ledger.Post(ctx, tx, ledger.Move(TransactionTypeTransfer, ledger.WalletAccount(fromID), ledger.WalletAccount(toID), amount))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO transactions (employee_id, counterparty_id, amount, transaction_type, transfer_id, created_at) VALUES ($1, $2, $3, $4, $5, $6), ($2, $1, $7, $4, $5, $6)`,
+			fromID, toID, -amount, TransactionTypeTransfer, transferID, time.Now(), amount,
+		)
+		return err
+	})
+	if err == nil {
+		r.metrics.CoinsTransferred(amount)
+	}
+	return err
 }
 
-func (r *repositoryImpl) GetEmployeeByID(id int) (models.Employee, error) {
+func (r *repositoryImpl) GetEmployeeByID(ctx context.Context, id int) (models.Employee, error) {
 	var emp models.Employee
-	err := r.db.QueryRow(
-		`SELECT id, username, coin_balance, created_at FROM employees WHERE id = $1`,
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, username, password_hash, role, coin_balance, created_at FROM employees WHERE id = $1`,
 		id,
-	).Scan(&emp.ID, &emp.Username, &emp.CoinBalance, &emp.CreatedAt)
+	).Scan(&emp.ID, &emp.Username, &emp.PasswordHash, &emp.Role, &emp.CoinBalance, &emp.CreatedAt)
 	if err != nil {
-		return emp, err
+		return emp, notFound(err)
 	}
 	return emp, nil
 }
 
-func (r *repositoryImpl) GetEmployeeByUsername(username string) (models.Employee, error) {
+func (r *repositoryImpl) GetEmployeeByUsername(ctx context.Context, username string) (models.Employee, error) {
 	var emp models.Employee
-	err := r.db.QueryRow(
-		`SELECT id, username, coin_balance, created_at FROM employees WHERE username = $1`,
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, username, password_hash, role, coin_balance, created_at FROM employees WHERE username = $1`,
 		username,
-	).Scan(&emp.ID, &emp.Username, &emp.CoinBalance, &emp.CreatedAt)
+	).Scan(&emp.ID, &emp.Username, &emp.PasswordHash, &emp.Role, &emp.CoinBalance, &emp.CreatedAt)
 	if err != nil {
-		return emp, err
+		return emp, notFound(err)
 	}
 	return emp, nil
 }
 
-func (r *repositoryImpl) GetWalletInfo(employeeID int) (int, []models.Transaction, error) {
+// GetWalletInfo returns the balance and the recent transfers of an employee.
+// Grants and bonuses have no sending employee and are listed only by
+// ListTransactions.
+func (r *repositoryImpl) GetWalletInfo(ctx context.Context, employeeID int) (int, []models.Transaction, error) {
 
 	var balance int
-	err := r.db.QueryRow(`SELECT coin_balance FROM employees WHERE id = $1`, employeeID).Scan(&balance)
+	err := r.db.QueryRowContext(ctx, `SELECT coin_balance FROM employees WHERE id = $1`, employeeID).Scan(&balance)
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, notFound(err)
 	}
 
-	rows, err := r.db.Query(`
-        SELECT id, employee_id, counterparty_id, amount, transaction_type, created_at 
-        FROM transactions 
-        WHERE employee_id = $1 
-        ORDER BY created_at DESC
-    `, employeeID)
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT t.id, t.employee_id, COALESCE(t.counterparty_id, 0), COALESCE(e.username, ''), t.amount, t.transaction_type, t.transfer_id, t.created_at
+        FROM transactions t
+        LEFT JOIN employees e ON e.id = t.counterparty_id
+        WHERE t.employee_id = $1 AND t.transaction_type = $2
+        ORDER BY t.created_at DESC, t.id DESC
+        LIMIT $3
+    `, employeeID, TransactionTypeTransfer, recentTransactionsLimit)
 	if err != nil {
 		return balance, nil, err
 	}
@@ -154,7 +306,7 @@ func (r *repositoryImpl) GetWalletInfo(employeeID int) (int, []models.Transactio
 	var transactions []models.Transaction
 	for rows.Next() {
 		var t models.Transaction
-		if err := rows.Scan(&t.ID, &t.EmployeeID, &t.CounterpartyID, &t.Amount, &t.TransactionType, &t.CreatedAt); err != nil {
+		if err := rows.Scan(&t.ID, &t.EmployeeID, &t.CounterpartyID, &t.CounterpartyUsername, &t.Amount, &t.TransactionType, &t.TransferID, &t.CreatedAt); err != nil {
 			return balance, nil, err
 		}
 		transactions = append(transactions, t)
@@ -163,7 +315,33 @@ func (r *repositoryImpl) GetWalletInfo(employeeID int) (int, []models.Transactio
 	return balance, transactions, nil
 }
 
-func (r *repositoryImpl) GetInventory(employeeID int) ([]map[string]interface{}, error) {
+// VerifyBalance checks the cached coin_balance of an employee against the sum
+// of their wallet postings. Both are read from one REPEATABLE READ snapshot,
+// so a transfer committed in between cannot show up as a mismatch.
+func (r *repositoryImpl) VerifyBalance(ctx context.Context, employeeID int) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var cached int
+	err = tx.QueryRowContext(ctx, `SELECT coin_balance FROM employees WHERE id = $1`, employeeID).Scan(&cached)
+	if err != nil {
+		return notFound(err)
+	}
+
+	derived, err := ledger.Balance(ctx, tx, ledger.WalletAccount(employeeID))
+	if err != nil {
+		return err
+	}
+	if cached != derived {
+		return fmt.Errorf("%w: employee %d has %d coins, ledger says %d", ErrBalanceMismatch, employeeID, cached, derived)
+	}
+	return tx.Commit()
+}
+
+func (r *repositoryImpl) GetInventory(ctx context.Context, employeeID int) ([]map[string]interface{}, error) {
 
 	query := `
 		SELECT merch_name, SUM(quantity) AS total_quantity
@@ -172,7 +350,7 @@ func (r *repositoryImpl) GetInventory(employeeID int) ([]map[string]interface{},
 		GROUP BY merch_name
 	`
 
-	rows, err := r.db.Query(query, employeeID)
+	rows, err := r.db.QueryContext(ctx, query, employeeID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query inventory: %w", err)
 	}
@@ -1,12 +1,16 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
 	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"merch-store/internal/models"
 )
 
 func TestBuyMerch_InsufficientFunds(t *testing.T) {
@@ -23,12 +27,15 @@ func TestBuyMerch_InsufficientFunds(t *testing.T) {
 
 	mock.ExpectBegin()
 
-	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1`).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT price FROM merch_items WHERE name = $1 AND active`)).
+		WithArgs(merchName).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(price))
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1 FOR UPDATE`).
 		WithArgs(employeeID).
 		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(totalCost - 10))
 	mock.ExpectRollback()
 
-	err = repo.BuyMerch(employeeID, merchName, quantity)
+	_, _, err = repo.BuyMerch(context.Background(), employeeID, merchName, quantity)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), ErrInsufficientFunds.Error())
 
@@ -49,22 +56,112 @@ func TestBuyMerch_Success(t *testing.T) {
 
 	mock.ExpectBegin()
 
-	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1`).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT price FROM merch_items WHERE name = $1 AND active`)).
+		WithArgs(merchName).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(price))
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1 FOR UPDATE`).
 		WithArgs(employeeID).
 		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(totalCost + 100))
 
-	mock.ExpectExec(`UPDATE employees SET coin_balance = coin_balance - \$1 WHERE id = \$2`).
+	mock.ExpectQuery(`UPDATE employees SET coin_balance = coin_balance - \$1 WHERE id = \$2 RETURNING coin_balance`).
 		WithArgs(totalCost, employeeID).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(100))
 
-	mock.ExpectExec(`INSERT INTO purchases`).
+	mock.ExpectQuery(`INSERT INTO purchases`).
 		WithArgs(employeeID, merchName, price, quantity, sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	expectLedgerMove(mock, "purchase", "wallet:1", "store:revenue", totalCost, 5)
 
 	mock.ExpectCommit()
 
-	err = repo.BuyMerch(employeeID, merchName, quantity)
+	purchase, balance, err := repo.BuyMerch(context.Background(), employeeID, merchName, quantity)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, purchase.ID)
+	assert.Equal(t, totalCost, purchase.Total())
+	assert.Equal(t, 100, balance)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuyMerch_InvalidMerch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT price FROM merch_items WHERE name = $1 AND active`)).
+		WithArgs("yacht").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, _, err = repo.BuyMerch(context.Background(), 1, "yacht", 1)
+	assert.ErrorIs(t, err, ErrInvalidMerch)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListMerchItems(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	createdAt := time.Now()
+
+	rows := sqlmock.NewRows([]string{"name", "title", "description", "price", "active", "created_at"}).
+		AddRow("cup", "Cup", "", 20, true, createdAt).
+		AddRow("pen", "Pen", "", 10, false, createdAt)
+	mock.ExpectQuery(`SELECT name, title, description, price, active, created_at FROM merch_items ORDER BY name`).
+		WillReturnRows(rows)
+
+	items, err := repo.ListMerchItems(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, "cup", items[0].Name)
+		assert.Equal(t, 10, items[1].Price)
+		assert.False(t, items[1].Active, "снятые с продажи товары тоже возвращаются")
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMerchItem(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	createdAt := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT name, title, description, price, active, created_at FROM merch_items WHERE name = $1`)).
+		WithArgs("mug").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "title", "description", "price", "active", "created_at"}).
+			AddRow("mug", "Mug", "", 15, false, createdAt))
+
+	item, err := repo.GetMerchItem(context.Background(), "mug")
 	assert.NoError(t, err)
+	assert.Equal(t, 15, item.Price)
+	assert.False(t, item.Active, "снятый с продажи товар тоже возвращается")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMerchItem_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT name, title, description, price, active, created_at FROM merch_items WHERE name = $1`)).
+		WithArgs("yacht").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.GetMerchItem(context.Background(), "yacht")
+	assert.ErrorIs(t, err, ErrInvalidMerch)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -81,12 +178,15 @@ func TestTransferCoins_InsufficientFunds(t *testing.T) {
 
 	mock.ExpectBegin()
 
-	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1`).
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1 FOR UPDATE`).
 		WithArgs(employeeID).
 		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(10))
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1 FOR UPDATE`).
+		WithArgs(toID).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(500))
 	mock.ExpectRollback()
 
-	err = repo.TransferCoins(employeeID, toID, amount)
+	err = repo.TransferCoins(context.Background(), employeeID, toID, amount)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), ErrInsufficientFunds.Error())
 
@@ -98,19 +198,18 @@ func TestTransferCoins_Success(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	repo := NewRepository(db)
+	metrics := &recordingMetrics{}
+	repo := NewRepository(db, WithMetrics(metrics))
 	fromID, toID := 1, 2
 	amount := 50
 
 	mock.ExpectBegin()
 
-	fromBalanceQuery := regexp.QuoteMeta(`SELECT coin_balance FROM employees WHERE id = $1`)
-	mock.ExpectQuery(fromBalanceQuery).
+	lockQuery := regexp.QuoteMeta(`SELECT coin_balance FROM employees WHERE id = $1 FOR UPDATE`)
+	mock.ExpectQuery(lockQuery).
 		WithArgs(fromID).
 		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(100))
-
-	toBalanceQuery := regexp.QuoteMeta(`SELECT coin_balance FROM employees WHERE id = $1`)
-	mock.ExpectQuery(toBalanceQuery).
+	mock.ExpectQuery(lockQuery).
 		WithArgs(toID).
 		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(200))
 
@@ -124,17 +223,95 @@ func TestTransferCoins_Success(t *testing.T) {
 		WithArgs(amount, toID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	insTx := regexp.QuoteMeta(`INSERT INTO transactions (employee_id, counterparty_id, amount, transaction_type, created_at) VALUES ($1, $2, $3, $4, $5)`)
+	expectLedgerMove(mock, "transfer", "wallet:1", "wallet:2", amount, 7)
+
+	insTx := regexp.QuoteMeta(`INSERT INTO transactions (employee_id, counterparty_id, amount, transaction_type, transfer_id, created_at) VALUES ($1, $2, $3, $4, $5, $6), ($2, $1, $7, $4, $5, $6)`)
 	mock.ExpectExec(insTx).
-		WithArgs(fromID, toID, amount, "transfer", sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WithArgs(fromID, toID, -amount, "transfer", int64(7), sqlmock.AnyArg(), amount).
+		WillReturnResult(sqlmock.NewResult(2, 2))
 
 	mock.ExpectCommit()
 
-	err = repo.TransferCoins(fromID, toID, amount)
+	err = repo.TransferCoins(context.Background(), fromID, toID, amount)
 	assert.NoError(t, err)
+	assert.Equal(t, amount, metrics.transferred)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+func TestTransferCoins_LocksInAscendingOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	fromID, toID := 9, 3
+
+	mock.ExpectBegin()
+	lockQuery := regexp.QuoteMeta(`SELECT coin_balance FROM employees WHERE id = $1 FOR UPDATE`)
+	mock.ExpectQuery(lockQuery).
+		WithArgs(toID).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(0))
+	mock.ExpectQuery(lockQuery).
+		WithArgs(fromID).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(0))
+	mock.ExpectRollback()
+
+	err = repo.TransferCoins(context.Background(), fromID, toID, 10)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuyMerch_RetriesOnDeadlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	lockQuery := regexp.QuoteMeta(`SELECT coin_balance FROM employees WHERE id = $1 FOR UPDATE`)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT price FROM merch_items WHERE name = $1 AND active`)).
+		WithArgs("pen").
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(10))
+	mock.ExpectQuery(lockQuery).
+		WithArgs(1).
+		WillReturnError(&pq.Error{Code: "40P01"})
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT price FROM merch_items WHERE name = $1 AND active`)).
+		WithArgs("pen").
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(10))
+	mock.ExpectQuery(lockQuery).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(5))
+	mock.ExpectRollback()
+
+	_, _, err = repo.BuyMerch(context.Background(), 1, "pen", 1)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Ожидание перед повтором прерывается, когда запрос отменён
+func TestBuyMerch_RetryStopsWhenContextDone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT price FROM merch_items WHERE name = $1 AND active`)).
+		WithArgs("pen").
+		WillReturnError(&pq.Error{Code: "40001"})
+	mock.ExpectRollback()
+
+	ctx, cancel := context.WithTimeout(context.Background(), txRetryDelay/2)
+	defer cancel()
+	_, _, err = repo.BuyMerch(ctx, 1, "pen", 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NoError(t, mock.ExpectationsWereMet(), "после отмены транзакция не должна повторяться")
+}
+
 func TestGetWalletInfo(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -148,17 +325,22 @@ func TestGetWalletInfo(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(1000))
 
 	createdAt := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "employee_id", "counterparty_id", "amount", "transaction_type", "created_at"}).
-		AddRow(1, employeeID, 2, 50, "transfer", createdAt).
-		AddRow(2, employeeID, 3, -30, "transfer", createdAt)
-	mock.ExpectQuery(`SELECT id, employee_id, counterparty_id, amount, transaction_type, created_at FROM transactions WHERE employee_id = \$1 ORDER BY created_at DESC`).
-		WithArgs(employeeID).
+	rows := sqlmock.NewRows([]string{"id", "employee_id", "counterparty_id", "username", "amount", "transaction_type", "transfer_id", "created_at"}).
+		AddRow(1, employeeID, 2, "bob", 50, "transfer", 10, createdAt).
+		AddRow(2, employeeID, 3, "carol", -30, "transfer", 11, createdAt)
+	mock.ExpectQuery(`SELECT t.id, t.employee_id, COALESCE\(t.counterparty_id, 0\), COALESCE\(e.username, ''\), t.amount, t.transaction_type, t.transfer_id, t.created_at FROM transactions t LEFT JOIN employees e ON e.id = t.counterparty_id WHERE t.employee_id = \$1 AND t.transaction_type = \$2 ORDER BY t.created_at DESC, t.id DESC LIMIT \$3`).
+		WithArgs(employeeID, TransactionTypeTransfer, recentTransactionsLimit).
 		WillReturnRows(rows)
 
-	balance, transactions, err := repo.GetWalletInfo(employeeID)
+	balance, transactions, err := repo.GetWalletInfo(context.Background(), employeeID)
 	assert.NoError(t, err)
 	assert.Equal(t, 1000, balance)
 	assert.Len(t, transactions, 2)
+	if assert.NotNil(t, transactions[0].TransferID) {
+		assert.Equal(t, int64(10), *transactions[0].TransferID)
+	}
+	assert.Equal(t, "bob", transactions[0].CounterpartyUsername)
+	assert.Equal(t, "carol", transactions[1].CounterpartyUsername)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -178,7 +360,7 @@ func TestGetInventory(t *testing.T) {
 		WithArgs(employeeID).
 		WillReturnRows(rows)
 
-	inventory, err := repo.GetInventory(employeeID)
+	inventory, err := repo.GetInventory(context.Background(), employeeID)
 	assert.NoError(t, err)
 	assert.Len(t, inventory, 2)
 
@@ -196,3 +378,148 @@ func TestGetInventory(t *testing.T) {
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestSetInitialPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	query := regexp.QuoteMeta(`UPDATE employees SET password_hash = $1 WHERE id = $2 AND password_hash = '' AND NOT EXISTS (SELECT 1 FROM employee_identities WHERE employee_id = $2)`)
+
+	mock.ExpectExec(query).WithArgs("hash", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	set, err := repo.SetInitialPassword(context.Background(), 1, "hash")
+	assert.NoError(t, err)
+	assert.True(t, set)
+
+	// Пароль уже задан или учётная запись привязана к SSO
+	mock.ExpectExec(query).WithArgs("hash", 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	set, err = repo.SetInitialPassword(context.Background(), 2, "hash")
+	assert.NoError(t, err)
+	assert.False(t, set)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateEmployee(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	createdAt := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO employees (username, password_hash, coin_balance, created_at) VALUES ($1, $2, $3, $4) RETURNING id, username, password_hash, role, coin_balance, created_at`)).
+		WithArgs("alice", "hash", 1000, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "coin_balance", "created_at"}).
+			AddRow(1, "alice", "hash", "employee", 1000, createdAt))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO ledger_accounts (id, kind, employee_id, created_at) VALUES ($1, $2, $3, $4)`)).
+		WithArgs("wallet:1", "wallet", 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLedgerMove(mock, "welcome", "mint:issuance", "wallet:1", 1000, 1)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO transactions (employee_id, amount, transaction_type, transfer_id, created_at) VALUES ($1, $2, $3, $4, $5)`)).
+		WithArgs(1, 1000, "welcome", int64(1), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	emp, err := repo.CreateEmployee(context.Background(), "alice", "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", emp.Username)
+	assert.Equal(t, "hash", emp.PasswordHash)
+	assert.Equal(t, models.RoleEmployee, emp.Role)
+	assert.Equal(t, 1000, emp.CoinBalance)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateEmployee_UsernameTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO employees (username, password_hash, coin_balance, created_at) VALUES ($1, $2, $3, $4) RETURNING id, username, password_hash, role, coin_balance, created_at`)).
+		WithArgs("alice", "hash", 1000, sqlmock.AnyArg()).
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	_, err = repo.CreateEmployee(context.Background(), "alice", "hash")
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyBalance_Mismatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT coin_balance FROM employees WHERE id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(900))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(amount), 0) FROM ledger_postings WHERE account_id = $1`)).
+		WithArgs("wallet:1").
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(1000))
+	mock.ExpectRollback()
+
+	err = repo.VerifyBalance(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrBalanceMismatch)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectLedgerMove ожидает запись двухстрочной проводки ledger.Move
+func expectLedgerMove(mock sqlmock.Sqlmock, kind, from, to string, amount int, entryID int64) {
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO journal_entries (kind, created_at) VALUES ($1, $2) RETURNING id`)).
+		WithArgs(kind, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(entryID))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO ledger_postings (entry_id, account_id, amount) VALUES ($1, $2, $3), ($1, $4, $5)`)).
+		WithArgs(entryID, from, -amount, to, amount).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+}
+
+func TestGetEmployeeByUsername_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, username, password_hash, role, coin_balance, created_at FROM employees WHERE username = $1`)).
+		WithArgs("ghost").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.GetEmployeeByUsername(context.Background(), "ghost")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, err, sql.ErrNoRows, "sql.ErrNoRows не должна выходить за пределы репозитория")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransferCoins_RecipientNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT coin_balance FROM employees WHERE id = $1 FOR UPDATE`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}).AddRow(100))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT coin_balance FROM employees WHERE id = $1 FOR UPDATE`)).
+		WithArgs(2).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = repo.TransferCoins(context.Background(), 1, 2, 50)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"merch-store/internal/models"
+)
+
+// SetEmployeeRole changes the role of an employee and records who changed it.
+// The new role is picked up the next time the employee gets a token. An
+// actorID of 0 records a change made from the command line, which is how the
+// first administrator is appointed.
+func (r *repositoryImpl) SetEmployeeRole(ctx context.Context, actorID, employeeID int, role string) error {
+	if !models.ValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		var oldRole string
+		err := tx.QueryRowContext(ctx, `SELECT role FROM employees WHERE id = $1 FOR UPDATE`, employeeID).Scan(&oldRole)
+		if err != nil {
+			return notFound(err)
+		}
+		if oldRole == role {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE employees SET role = $1 WHERE id = $2`, role, employeeID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO role_changes (employee_id, old_role, new_role, changed_by, created_at) VALUES ($1, $2, $3, NULLIF($4, 0), $5)`,
+			employeeID, oldRole, role, actorID, time.Now(),
+		)
+		return err
+	})
+}
+
+func (r *repositoryImpl) ListRoleChanges(ctx context.Context, employeeID int) ([]models.RoleChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT rc.id, rc.employee_id, rc.old_role, rc.new_role, COALESCE(rc.changed_by, 0), COALESCE(e.username, ''), rc.created_at
+		FROM role_changes rc
+		LEFT JOIN employees e ON e.id = rc.changed_by
+		WHERE rc.employee_id = $1
+		ORDER BY rc.created_at DESC, rc.id DESC
+	`, employeeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []models.RoleChange
+	for rows.Next() {
+		var change models.RoleChange
+		if err := rows.Scan(&change.ID, &change.EmployeeID, &change.OldRole, &change.NewRole, &change.ChangedBy, &change.ChangedByUsername, &change.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return changes, nil
+}
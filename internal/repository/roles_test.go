@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/models"
+)
+
+func TestSetEmployeeRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT role FROM employees WHERE id = $1 FOR UPDATE`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleEmployee))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE employees SET role = $1 WHERE id = $2`)).
+		WithArgs(models.RoleStoreManager, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO role_changes (employee_id, old_role, new_role, changed_by, created_at) VALUES ($1, $2, $3, NULLIF($4, 0), $5)`)).
+		WithArgs(5, models.RoleEmployee, models.RoleStoreManager, 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.SetEmployeeRole(context.Background(), 1, 5, models.RoleStoreManager)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetEmployeeRole_InvalidRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	err = repo.SetEmployeeRole(context.Background(), 1, 5, "emperor")
+	assert.ErrorIs(t, err, ErrInvalidRole)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListRoleChanges(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	createdAt := time.Now()
+	// Первого администратора назначают командой promote, без автора изменения
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT rc.id, rc.employee_id, rc.old_role, rc.new_role, COALESCE(rc.changed_by, 0), COALESCE(e.username, ''), rc.created_at FROM role_changes rc LEFT JOIN employees e ON e.id = rc.changed_by WHERE rc.employee_id = $1`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "employee_id", "old_role", "new_role", "changed_by", "username", "created_at"}).
+			AddRow(2, 5, models.RoleFinanceAdmin, models.RoleStoreManager, 1, "boss", createdAt).
+			AddRow(1, 5, models.RoleEmployee, models.RoleFinanceAdmin, 0, "", createdAt))
+
+	changes, err := repo.ListRoleChanges(context.Background(), 5)
+	assert.NoError(t, err)
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, "boss", changes[0].ChangedByUsername)
+		assert.Equal(t, 0, changes[1].ChangedBy)
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"merch-store/internal/models"
+)
+
+// CreateSession starts a login session with its first refresh token. Only the
+// hash of the refresh token is stored.
+func (r *repositoryImpl) CreateSession(ctx context.Context, employeeID int, sessionID, refreshTokenHash string, expiresAt time.Time) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO auth_sessions (id, employee_id, created_at) VALUES ($1, $2, $3)`,
+			sessionID, employeeID, now,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO refresh_tokens (token_hash, session_id, expires_at, created_at) VALUES ($1, $2, $3, $4)`,
+			refreshTokenHash, sessionID, expiresAt, now,
+		)
+		return err
+	})
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same
+// session. A refresh token can be used only once: presenting an already used
+// token means it has leaked, so the whole session is revoked and
+// ErrTokenReused is returned.
+func (r *repositoryImpl) RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string, expiresAt time.Time) (models.Session, error) {
+	var session models.Session
+	var reused bool
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		reused = false
+		now := time.Now()
+
+		var tokenExpiresAt time.Time
+		var usedAt sql.NullTime
+		var revokedAt sql.NullTime
+		err := tx.QueryRowContext(ctx, `
+			SELECT s.id, s.employee_id, s.revoked_at, s.created_at, rt.expires_at, rt.used_at
+			FROM refresh_tokens rt
+			JOIN auth_sessions s ON s.id = rt.session_id
+			WHERE rt.token_hash = $1
+			FOR UPDATE
+		`, refreshTokenHash).Scan(&session.ID, &session.EmployeeID, &revokedAt, &session.CreatedAt, &tokenExpiresAt, &usedAt)
+		if err != nil {
+			return notFound(err)
+		}
+
+		switch {
+		case revokedAt.Valid:
+			return ErrSessionRevoked
+		case usedAt.Valid:
+			_, err = tx.ExecContext(ctx, `UPDATE auth_sessions SET revoked_at = $1 WHERE id = $2`, now, session.ID)
+			reused = err == nil
+			return err
+		case !tokenExpiresAt.After(now):
+			return ErrTokenExpired
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = $1 WHERE token_hash = $2`, now, refreshTokenHash)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO refresh_tokens (token_hash, session_id, expires_at, created_at) VALUES ($1, $2, $3, $4)`,
+			newRefreshTokenHash, session.ID, expiresAt, now,
+		)
+		return err
+	})
+	if err != nil {
+		return models.Session{}, err
+	}
+	// The revocation has to be committed, so reuse is reported only after
+	// the transaction.
+	if reused {
+		return models.Session{}, ErrTokenReused
+	}
+	return session, nil
+}
+
+func (r *repositoryImpl) RevokeSession(ctx context.Context, sessionID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE auth_sessions SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`,
+		time.Now(), sessionID,
+	)
+	return err
+}
+
+// SessionRevoked reports whether the session was revoked. Unknown sessions
+// are reported as revoked.
+func (r *repositoryImpl) SessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRowContext(ctx, `SELECT revoked_at IS NOT NULL FROM auth_sessions WHERE id = $1`, sessionID).Scan(&revoked)
+	if errors.Is(notFound(err), ErrNotFound) {
+		return true, nil
+	}
+	return revoked, err
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const selectRefreshToken = `SELECT s.id, s.employee_id, s.revoked_at, s.created_at, rt.expires_at, rt.used_at\s+FROM refresh_tokens rt`
+
+func TestCreateSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	expiresAt := time.Now().Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO auth_sessions (id, employee_id, created_at) VALUES ($1, $2, $3)`)).
+		WithArgs("session-1", 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO refresh_tokens (token_hash, session_id, expires_at, created_at) VALUES ($1, $2, $3, $4)`)).
+		WithArgs("hash-1", "session-1", expiresAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.CreateSession(context.Background(), 1, "session-1", "hash-1", expiresAt)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshToken_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	expiresAt := time.Now().Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectRefreshToken).
+		WithArgs("hash-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "employee_id", "revoked_at", "created_at", "expires_at", "used_at"}).
+			AddRow("session-1", 1, nil, time.Now(), expiresAt, nil))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE refresh_tokens SET used_at = $1 WHERE token_hash = $2`)).
+		WithArgs(sqlmock.AnyArg(), "hash-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO refresh_tokens (token_hash, session_id, expires_at, created_at) VALUES ($1, $2, $3, $4)`)).
+		WithArgs("hash-2", "session-1", expiresAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	session, err := repo.RotateRefreshToken(context.Background(), "hash-1", "hash-2", expiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, "session-1", session.ID)
+	assert.Equal(t, 1, session.EmployeeID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Повторное использование refresh токена отзывает сессию, и отзыв фиксируется
+func TestRotateRefreshToken_ReuseRevokesSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectRefreshToken).
+		WithArgs("hash-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "employee_id", "revoked_at", "created_at", "expires_at", "used_at"}).
+			AddRow("session-1", 1, nil, time.Now(), time.Now().Add(time.Hour), time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE auth_sessions SET revoked_at = $1 WHERE id = $2`)).
+		WithArgs(sqlmock.AnyArg(), "session-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err = repo.RotateRefreshToken(context.Background(), "hash-1", "hash-2", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrTokenReused)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshToken_Expired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectRefreshToken).
+		WithArgs("hash-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "employee_id", "revoked_at", "created_at", "expires_at", "used_at"}).
+			AddRow("session-1", 1, nil, time.Now(), time.Now().Add(-time.Minute), nil))
+	mock.ExpectRollback()
+
+	_, err = repo.RotateRefreshToken(context.Background(), "hash-1", "hash-2", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrTokenExpired)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRevoked_UnknownSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT revoked_at IS NOT NULL FROM auth_sessions WHERE id = $1`)).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"revoked"}))
+
+	revoked, err := repo.SessionRevoked(context.Background(), "missing")
+	assert.NoError(t, err)
+	assert.True(t, revoked, "неизвестная сессия считается отозванной")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"merch-store/internal/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "merch-store/internal/repository"
+
+// WithTracerProvider sets where the repository spans go. Without it the
+// global tracer provider is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(r *repositoryImpl) {
+		r.tracerProvider = provider
+	}
+}
+
+// tracedRepository starts a span around every repository call. The SQL
+// statements the call runs are traced by the driver as children of that span,
+// see InitDB.
+type tracedRepository struct {
+	next   Repository
+	tracer trace.Tracer
+}
+
+func newTracedRepository(next Repository, provider trace.TracerProvider) Repository {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &tracedRepository{next: next, tracer: provider.Tracer(tracerName)}
+}
+
+func (t *tracedRepository) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "Repository."+method, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func employeeAttr(id int) attribute.KeyValue {
+	return attribute.Int("employee.id", id)
+}
+
+func (t *tracedRepository) CreateEmployee(ctx context.Context, username, passwordHash string) (models.Employee, error) {
+	ctx, span := t.start(ctx, "CreateEmployee")
+	emp, err := t.next.CreateEmployee(ctx, username, passwordHash)
+	endSpan(span, err)
+	return emp, err
+}
+
+func (t *tracedRepository) SetInitialPassword(ctx context.Context, id int, passwordHash string) (bool, error) {
+	ctx, span := t.start(ctx, "SetInitialPassword", employeeAttr(id))
+	set, err := t.next.SetInitialPassword(ctx, id, passwordHash)
+	endSpan(span, err)
+	return set, err
+}
+
+func (t *tracedRepository) GetEmployeeByID(ctx context.Context, id int) (models.Employee, error) {
+	ctx, span := t.start(ctx, "GetEmployeeByID", employeeAttr(id))
+	emp, err := t.next.GetEmployeeByID(ctx, id)
+	endSpan(span, err)
+	return emp, err
+}
+
+func (t *tracedRepository) GetEmployeeByUsername(ctx context.Context, username string) (models.Employee, error) {
+	ctx, span := t.start(ctx, "GetEmployeeByUsername")
+	emp, err := t.next.GetEmployeeByUsername(ctx, username)
+	endSpan(span, err)
+	return emp, err
+}
+
+func (t *tracedRepository) GetEmployeeByIdentity(ctx context.Context, issuer, subject string) (models.Employee, error) {
+	ctx, span := t.start(ctx, "GetEmployeeByIdentity", attribute.String("identity.issuer", issuer))
+	emp, err := t.next.GetEmployeeByIdentity(ctx, issuer, subject)
+	endSpan(span, err)
+	return emp, err
+}
+
+func (t *tracedRepository) LinkIdentity(ctx context.Context, id int, issuer, subject, email string) error {
+	ctx, span := t.start(ctx, "LinkIdentity", employeeAttr(id), attribute.String("identity.issuer", issuer))
+	err := t.next.LinkIdentity(ctx, id, issuer, subject, email)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) CreateSession(ctx context.Context, id int, sessionID, refreshTokenHash string, expiresAt time.Time) error {
+	ctx, span := t.start(ctx, "CreateSession", employeeAttr(id))
+	err := t.next.CreateSession(ctx, id, sessionID, refreshTokenHash, expiresAt)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string, expiresAt time.Time) (models.Session, error) {
+	ctx, span := t.start(ctx, "RotateRefreshToken")
+	session, err := t.next.RotateRefreshToken(ctx, refreshTokenHash, newRefreshTokenHash, expiresAt)
+	endSpan(span, err)
+	return session, err
+}
+
+func (t *tracedRepository) RevokeSession(ctx context.Context, sessionID string) error {
+	ctx, span := t.start(ctx, "RevokeSession")
+	err := t.next.RevokeSession(ctx, sessionID)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) SessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	ctx, span := t.start(ctx, "SessionRevoked")
+	revoked, err := t.next.SessionRevoked(ctx, sessionID)
+	endSpan(span, err)
+	return revoked, err
+}
+
+func (t *tracedRepository) SetEmployeeRole(ctx context.Context, actorID, id int, role string) error {
+	ctx, span := t.start(ctx, "SetEmployeeRole", employeeAttr(id), attribute.String("employee.role", role))
+	err := t.next.SetEmployeeRole(ctx, actorID, id, role)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) ListRoleChanges(ctx context.Context, id int) ([]models.RoleChange, error) {
+	ctx, span := t.start(ctx, "ListRoleChanges", employeeAttr(id))
+	changes, err := t.next.ListRoleChanges(ctx, id)
+	endSpan(span, err)
+	return changes, err
+}
+
+func (t *tracedRepository) ListMerchItems(ctx context.Context) ([]models.MerchItem, error) {
+	ctx, span := t.start(ctx, "ListMerchItems")
+	items, err := t.next.ListMerchItems(ctx)
+	endSpan(span, err)
+	return items, err
+}
+
+func (t *tracedRepository) GetMerchItem(ctx context.Context, name string) (models.MerchItem, error) {
+	ctx, span := t.start(ctx, "GetMerchItem", attribute.String("merch.name", name))
+	item, err := t.next.GetMerchItem(ctx, name)
+	endSpan(span, err)
+	return item, err
+}
+
+func (t *tracedRepository) BuyMerch(ctx context.Context, id int, merchName string, quantity int) (models.Purchase, int, error) {
+	ctx, span := t.start(ctx, "BuyMerch", employeeAttr(id), attribute.String("merch.name", merchName), attribute.Int("merch.quantity", quantity))
+	purchase, balance, err := t.next.BuyMerch(ctx, id, merchName, quantity)
+	endSpan(span, err)
+	return purchase, balance, err
+}
+
+func (t *tracedRepository) AddToCart(ctx context.Context, id int, merchName string, quantity int) error {
+	ctx, span := t.start(ctx, "AddToCart", employeeAttr(id), attribute.String("merch.name", merchName), attribute.Int("merch.quantity", quantity))
+	err := t.next.AddToCart(ctx, id, merchName, quantity)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) RemoveFromCart(ctx context.Context, id int, merchName string) error {
+	ctx, span := t.start(ctx, "RemoveFromCart", employeeAttr(id), attribute.String("merch.name", merchName))
+	err := t.next.RemoveFromCart(ctx, id, merchName)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) GetCart(ctx context.Context, id int) ([]models.CartItem, error) {
+	ctx, span := t.start(ctx, "GetCart", employeeAttr(id))
+	cart, err := t.next.GetCart(ctx, id)
+	endSpan(span, err)
+	return cart, err
+}
+
+func (t *tracedRepository) Checkout(ctx context.Context, id int) ([]models.Purchase, int, error) {
+	ctx, span := t.start(ctx, "Checkout", employeeAttr(id))
+	purchases, balance, err := t.next.Checkout(ctx, id)
+	endSpan(span, err)
+	return purchases, balance, err
+}
+
+func (t *tracedRepository) TransferCoins(ctx context.Context, fromID, toID, amount int) error {
+	ctx, span := t.start(ctx, "TransferCoins", employeeAttr(fromID), attribute.Int("recipient.id", toID), attribute.Int("coins.amount", amount))
+	err := t.next.TransferCoins(ctx, fromID, toID, amount)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) GrantCoins(ctx context.Context, actorID int, grants []models.Grant) ([]models.Transaction, error) {
+	ctx, span := t.start(ctx, "GrantCoins", attribute.Int("grants.count", len(grants)))
+	transactions, err := t.next.GrantCoins(ctx, actorID, grants)
+	endSpan(span, err)
+	return transactions, err
+}
+
+func (t *tracedRepository) ReleaseDueGrants(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := t.start(ctx, "ReleaseDueGrants")
+	released, err := t.next.ReleaseDueGrants(ctx, now)
+	endSpan(span, err)
+	return released, err
+}
+
+func (t *tracedRepository) GetWalletInfo(ctx context.Context, id int) (int, []models.Transaction, error) {
+	ctx, span := t.start(ctx, "GetWalletInfo", employeeAttr(id))
+	balance, transactions, err := t.next.GetWalletInfo(ctx, id)
+	endSpan(span, err)
+	return balance, transactions, err
+}
+
+func (t *tracedRepository) ListTransactions(ctx context.Context, id int, filter models.TransactionFilter) ([]models.Transaction, error) {
+	ctx, span := t.start(ctx, "ListTransactions", employeeAttr(id))
+	transactions, err := t.next.ListTransactions(ctx, id, filter)
+	endSpan(span, err)
+	return transactions, err
+}
+
+func (t *tracedRepository) GetInventory(ctx context.Context, id int) ([]map[string]interface{}, error) {
+	ctx, span := t.start(ctx, "GetInventory", employeeAttr(id))
+	inventory, err := t.next.GetInventory(ctx, id)
+	endSpan(span, err)
+	return inventory, err
+}
+
+func (t *tracedRepository) VerifyBalance(ctx context.Context, id int) error {
+	ctx, span := t.start(ctx, "VerifyBalance", employeeAttr(id))
+	err := t.next.VerifyBalance(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) ReserveIdempotencyKey(ctx context.Context, id int, key, fingerprint string, retention time.Duration) (models.IdempotencyRecord, bool, error) {
+	ctx, span := t.start(ctx, "ReserveIdempotencyKey", employeeAttr(id))
+	record, reserved, err := t.next.ReserveIdempotencyKey(ctx, id, key, fingerprint, retention)
+	endSpan(span, err)
+	return record, reserved, err
+}
+
+func (t *tracedRepository) CompleteIdempotencyKey(ctx context.Context, id int, key string, statusCode int, responseBody []byte) error {
+	ctx, span := t.start(ctx, "CompleteIdempotencyKey", employeeAttr(id))
+	err := t.next.CompleteIdempotencyKey(ctx, id, key, statusCode, responseBody)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) ReleaseIdempotencyKey(ctx context.Context, id int, key string) error {
+	ctx, span := t.start(ctx, "ReleaseIdempotencyKey", employeeAttr(id))
+	err := t.next.ReleaseIdempotencyKey(ctx, id, key)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedRepository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	ctx, span := t.start(ctx, "PurgeIdempotencyKeys")
+	purged, err := t.next.PurgeIdempotencyKeys(ctx, before)
+	endSpan(span, err)
+	return purged, err
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// newTracedMock возвращает пул с трассировкой SQL поверх sqlmock и
+// провайдер, записывающий спаны
+func newTracedMock(t *testing.T) (sqlmock.Sqlmock, *sql.DB, *tracetest.SpanRecorder, *sdktrace.TracerProvider) {
+	t.Helper()
+	dsn := t.Name()
+	_, mock, err := sqlmock.NewWithDSN(dsn)
+	require.NoError(t, err)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	db, err := openTraced("sqlmock", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return mock, db, recorder, provider
+}
+
+func TestTracing_SpansNestUnderRequest(t *testing.T) {
+	mock, db, recorder, provider := newTracedMock(t)
+	repo := NewRepository(db, WithTracerProvider(provider))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, username, password_hash, role, coin_balance, created_at FROM employees WHERE id = $1`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "coin_balance", "created_at"}).
+			AddRow(1, "alice", "hash", "employee", 1000, time.Now()))
+
+	ctx, request := provider.Tracer("test").Start(context.Background(), "GET /api/info")
+	_, err := repo.GetEmployeeByID(ctx, 1)
+	request.End()
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	// Запрос -> метод репозитория -> SQL-запрос
+	method, statement := spans["Repository.GetEmployeeByID"], spans["sql.conn.query"]
+	require.NotNil(t, method)
+	require.NotNil(t, statement)
+	assert.Equal(t, spans["GET /api/info"].SpanContext().SpanID(), method.Parent().SpanID())
+	assert.Equal(t, method.SpanContext().SpanID(), statement.Parent().SpanID())
+	assert.Equal(t, codes.Unset, method.Status().Code)
+}
+
+func TestTracing_RecordsError(t *testing.T) {
+	mock, db, recorder, provider := newTracedMock(t)
+	repo := NewRepository(db, WithTracerProvider(provider))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT coin_balance FROM employees WHERE id = $1`)).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"coin_balance"}))
+
+	_, _, err := repo.GetWalletInfo(context.Background(), 42)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	spans := recorder.Ended()
+	require.NotEmpty(t, spans)
+	method := spans[len(spans)-1]
+	assert.Equal(t, "Repository.GetWalletInfo", method.Name())
+	assert.Equal(t, codes.Error, method.Status().Code)
+}
+
+func TestTracing_SkipsStatementsOutsideTrace(t *testing.T) {
+	mock, db, recorder, _ := newTracedMock(t)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT 1`)).WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+
+	// Например, запросы проверки готовности
+	var one int
+	require.NoError(t, db.QueryRowContext(context.Background(), `SELECT 1`).Scan(&one))
+	assert.Empty(t, recorder.Ended())
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"merch-store/internal/models"
+)
+
+const (
+	DirectionSent     = "sent"
+	DirectionReceived = "received"
+)
+
+func (r *repositoryImpl) ListTransactions(ctx context.Context, employeeID int, filter models.TransactionFilter) ([]models.Transaction, error) {
+	conditions := []string{"t.employee_id = $1"}
+	args := []interface{}{employeeID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	switch filter.Direction {
+	case DirectionSent:
+		conditions = append(conditions, "t.amount < 0")
+	case DirectionReceived:
+		conditions = append(conditions, "t.amount > 0")
+	}
+	if filter.Counterparty != "" {
+		conditions = append(conditions, "e.username = "+arg(filter.Counterparty))
+	}
+	if filter.Type != "" {
+		conditions = append(conditions, "t.transaction_type = "+arg(filter.Type))
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "t.created_at >= "+arg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "t.created_at < "+arg(filter.To))
+	}
+	if filter.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(t.created_at, t.id) < (%s, %s)", arg(filter.After.CreatedAt), arg(filter.After.ID)))
+	}
+
+	query := `
+		SELECT t.id, t.employee_id, COALESCE(t.counterparty_id, 0), COALESCE(e.username, ''), t.amount, t.transaction_type, t.transfer_id, COALESCE(t.reason, ''), t.created_at
+		FROM transactions t
+		LEFT JOIN employees e ON e.id = t.counterparty_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY t.created_at DESC, t.id DESC
+		LIMIT ` + arg(filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var t models.Transaction
+		if err := rows.Scan(&t.ID, &t.EmployeeID, &t.CounterpartyID, &t.CounterpartyUsername, &t.Amount, &t.TransactionType, &t.TransferID, &t.Reason, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		transactions = append(transactions, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return transactions, nil
+}
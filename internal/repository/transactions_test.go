@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/migrations"
+	"merch-store/internal/models"
+)
+
+func TestListTransactions_Filters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRepository(db)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cursor := models.TransactionCursor{CreatedAt: from.Add(48 * time.Hour), ID: 7}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE t.employee_id = $1 AND t.amount < 0 AND e.username = $2 AND t.transaction_type = $3 AND t.created_at >= $4 AND (t.created_at, t.id) < ($5, $6) ORDER BY t.created_at DESC, t.id DESC LIMIT $7`)).
+		WithArgs(1, "bob", "transfer", from, cursor.CreatedAt, 7, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "employee_id", "counterparty_id", "username", "amount", "transaction_type", "transfer_id", "reason", "created_at"}).
+			AddRow(6, 1, 2, "bob", -10, "transfer", 3, "", from))
+
+	transactions, err := repo.ListTransactions(context.Background(), 1, models.TransactionFilter{
+		Direction:    DirectionSent,
+		Counterparty: "bob",
+		Type:         "transfer",
+		From:         from,
+		After:        &cursor,
+		Limit:        11,
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, transactions, 1) {
+		assert.Equal(t, "bob", transactions[0].CounterpartyUsername)
+		assert.Empty(t, transactions[0].Reason, "у переводов нет причины")
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Интеграционный тест: требует живой Postgres в TEST_DATABASE_URL
+func TestListTransactions_FilterWithOffset(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := InitDB(dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err = migrator.Up(context.Background()); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	repo := NewRepository(db)
+	suffix := time.Now().UnixNano()
+	sender, err := repo.CreateEmployee(context.Background(), fmt.Sprintf("tz-sender-%d", suffix), "x")
+	assert.NoError(t, err)
+	recipient, err := repo.CreateEmployee(context.Background(), fmt.Sprintf("tz-recipient-%d", suffix), "x")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.TransferCoins(context.Background(), sender.ID, recipient.ID, 10))
+
+	// Границы в часовом поясе Москвы задают тот же момент, что и в UTC
+	moscow := time.FixedZone("MSK", 3*60*60)
+	list := func(from, to time.Time) []models.Transaction {
+		transactions, err := repo.ListTransactions(context.Background(), sender.ID, models.TransactionFilter{
+			Type:  TransactionTypeTransfer,
+			From:  from.In(moscow),
+			To:    to.In(moscow),
+			Limit: 10,
+		})
+		assert.NoError(t, err)
+		return transactions
+	}
+	now := time.Now()
+	assert.Len(t, list(now.Add(-time.Minute), now.Add(time.Minute)), 1, "перевод попадает в интервал вокруг текущего момента")
+	assert.Empty(t, list(now.Add(time.Minute), now.Add(time.Hour)), "смещение не должно сдвигать интервал на три часа")
+}
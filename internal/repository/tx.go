@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	maxTxAttempts = 5
+	txRetryDelay  = 10 * time.Millisecond
+)
+
+// inTx runs fn in a transaction and commits it. Transactions aborted by
+// Postgres because of a serialization failure or a deadlock are retried from
+// scratch, so fn must not have side effects outside of tx. Waiting between
+// attempts stops as soon as ctx is done.
+func (r *repositoryImpl) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = r.runTx(ctx, fn)
+		if !isRetryable(err) || attempt == maxTxAttempts {
+			return err
+		}
+		timer := time.NewTimer(time.Duration(attempt) * txRetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+func (r *repositoryImpl) runTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return true
+	}
+	return false
+}
+
+// lockWallets locks the employee rows with SELECT ... FOR UPDATE and returns
+// their balances. Rows are always locked in ascending id order so that two
+// transactions touching the same wallets cannot deadlock each other. A missing
+// employee is reported as ErrNotFound.
+func lockWallets(ctx context.Context, tx *sql.Tx, ids ...int) (map[int]int, error) {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+
+	balances := make(map[int]int, len(sorted))
+	for _, id := range sorted {
+		if _, ok := balances[id]; ok {
+			continue
+		}
+		var balance int
+		err := tx.QueryRowContext(ctx, `SELECT coin_balance FROM employees WHERE id = $1 FOR UPDATE`, id).Scan(&balance)
+		if err != nil {
+			return nil, notFound(err)
+		}
+		balances[id] = balance
+	}
+	return balances, nil
+}
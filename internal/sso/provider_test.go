@@ -0,0 +1,83 @@
+package sso
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	"merch-store/internal/sso/ssotest"
+)
+
+const redirectURL = "http://merch.example.com/api/auth/oidc/callback"
+
+// authorize проходит вход у тестового провайдера и возвращает код авторизации
+func authorize(t *testing.T, provider *Provider, nonce, verifier string) string {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(provider.AuthCodeURL("state-1", nonce, verifier))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "state-1", location.Query().Get("state"))
+	return location.Query().Get("code")
+}
+
+func newTestProvider(t *testing.T) (*Provider, *ssotest.Server) {
+	t.Helper()
+	idp := ssotest.NewServer("merch-store", "secret")
+	t.Cleanup(idp.Close)
+
+	provider, err := NewProvider(context.Background(), Config{
+		IssuerURL:    idp.URL,
+		ClientID:     "merch-store",
+		ClientSecret: "secret",
+		RedirectURL:  redirectURL,
+	})
+	assert.NoError(t, err)
+	return provider, idp
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	idp.SetIdentity(ssotest.Identity{Subject: "sub-1", Email: "Alice@Example.com", EmailVerified: true})
+
+	verifier := oauth2.GenerateVerifier()
+	code := authorize(t, provider, "nonce-1", verifier)
+
+	identity, err := provider.Exchange(context.Background(), code, verifier, "nonce-1")
+	assert.NoError(t, err)
+	assert.Equal(t, idp.URL, identity.Issuer)
+	assert.Equal(t, "sub-1", identity.Subject)
+	assert.Equal(t, "alice@example.com", identity.Email, "email приводится к нижнему регистру")
+	assert.True(t, identity.EmailVerified)
+}
+
+func TestProvider_Exchange_NonceMismatch(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	idp.SetIdentity(ssotest.Identity{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true})
+
+	verifier := oauth2.GenerateVerifier()
+	code := authorize(t, provider, "nonce-1", verifier)
+
+	_, err := provider.Exchange(context.Background(), code, verifier, "nonce-2")
+	assert.ErrorIs(t, err, ErrNonceMismatch)
+}
+
+// Код авторизации без правильного PKCE verifier не обменивается на токены
+func TestProvider_Exchange_WrongVerifier(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	idp.SetIdentity(ssotest.Identity{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true})
+
+	code := authorize(t, provider, "nonce-1", oauth2.GenerateVerifier())
+
+	_, err := provider.Exchange(context.Background(), code, oauth2.GenerateVerifier(), "nonce-1")
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// resetGlobals возвращает глобальный провайдер трассировки после теста
+func resetGlobals(t *testing.T) {
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+}
+
+func TestSetup_Stdout(t *testing.T) {
+	resetGlobals(t)
+	var buf bytes.Buffer
+	stdout = &buf
+	t.Cleanup(func() { stdout = os.Stdout })
+
+	shutdown, err := Setup(context.Background(), ExporterStdout)
+	require.NoError(t, err)
+	_, span := otel.Tracer("test").Start(context.Background(), "test-span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	assert.Contains(t, buf.String(), "test-span")
+	assert.Contains(t, buf.String(), ServiceName)
+}
+
+func TestSetup_OTLP(t *testing.T) {
+	resetGlobals(t)
+	var received atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/traces" {
+			received.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+
+	shutdown, err := Setup(context.Background(), ExporterOTLP)
+	require.NoError(t, err)
+	_, span := otel.Tracer("test").Start(context.Background(), "test-span")
+	span.End()
+	// Shutdown отправляет накопленные спаны
+	require.NoError(t, shutdown(context.Background()))
+
+	assert.Equal(t, int32(1), received.Load())
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	resetGlobals(t)
+	_, err := Setup(context.Background(), "jaeger")
+	assert.Error(t, err)
+}
+
+func TestMiddleware_ContinuesTrace(t *testing.T) {
+	resetGlobals(t)
+	_, err := Setup(context.Background(), ExporterNone)
+	require.NoError(t, err)
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	var handlerSpan trace.SpanContext
+	router.GET("/api/info", func(c *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+	router.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req, _ := http.NewRequest("GET", "/api/info", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	req, _ = http.NewRequest("GET", "/healthz", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Спан запроса продолжает трассу вызывающего сервиса, пробы не трассируются
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "/api/info", spans[0].Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.Equal(t, spans[0].SpanContext().SpanID(), handlerSpan.SpanID())
+}
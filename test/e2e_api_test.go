@@ -2,7 +2,9 @@ package e2e
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -18,34 +20,68 @@ import (
 )
 
 // TestRepo – in‑memory реализация интерфейса для e2e тестов
+// testTokens выдаёт и проверяет токены в тестах
+var testTokens = newTestTokens()
+
+func newTestTokens() *middleware.Tokens {
+	keys, err := middleware.GenerateKeySet()
+	if err != nil {
+		panic(err)
+	}
+	return middleware.NewTokens(keys, "merch-store", "merch-store")
+}
+
 type TestRepo struct {
-	mu        sync.Mutex
-	employees map[int]models.Employee
-	nextID    int
+	mu             sync.Mutex
+	employees      map[int]models.Employee
+	carts          map[int]map[string]int
+	idempotency    map[string]models.IdempotencyRecord
+	transactions   []models.Transaction
+	identities     map[string]int
+	sessions       map[string]*models.Session
+	refreshTokens  map[string]*testRefreshToken
+	nextID         int
+	nextTransferID int64
 }
 
 func NewTestRepo() *TestRepo {
 	return &TestRepo{
-		employees: make(map[int]models.Employee),
-		nextID:    1,
+		employees:     make(map[int]models.Employee),
+		carts:         make(map[int]map[string]int),
+		idempotency:   make(map[string]models.IdempotencyRecord),
+		identities:    make(map[string]int),
+		sessions:      make(map[string]*models.Session),
+		refreshTokens: make(map[string]*testRefreshToken),
+		nextID:        1,
 	}
 }
 
-func (r *TestRepo) CreateEmployee(username string) (models.Employee, error) {
+func (r *TestRepo) CreateEmployee(ctx context.Context, username, passwordHash string) (models.Employee, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	for _, existing := range r.employees {
+		if existing.Username == username {
+			return models.Employee{}, repository.ErrAlreadyExists
+		}
+	}
 	emp := models.Employee{
-		ID:          r.nextID,
-		Username:    username,
-		CoinBalance: 1000,
-		CreatedAt:   time.Now(),
+		ID:           r.nextID,
+		Username:     username,
+		PasswordHash: passwordHash,
+		Role:         models.RoleEmployee,
+		CoinBalance:  1000,
+		CreatedAt:    time.Now(),
 	}
 	r.employees[r.nextID] = emp
+	r.nextTransferID++
+	welcomeID := r.nextTransferID
+	// Приветственный бонус, как в репозитории с политикой по умолчанию
+	r.transactions = append(r.transactions, models.Transaction{EmployeeID: emp.ID, Amount: 1000, TransactionType: repository.TransactionTypeWelcome, TransferID: &welcomeID, CreatedAt: emp.CreatedAt})
 	r.nextID++
 	return emp, nil
 }
 
-func (r *TestRepo) GetEmployeeByUsername(username string) (models.Employee, error) {
+func (r *TestRepo) GetEmployeeByUsername(ctx context.Context, username string) (models.Employee, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	for _, emp := range r.employees {
@@ -56,7 +92,55 @@ func (r *TestRepo) GetEmployeeByUsername(username string) (models.Employee, erro
 	return models.Employee{}, repository.ErrNotFound
 }
 
-func (r *TestRepo) GetEmployeeByID(id int) (models.Employee, error) {
+func (r *TestRepo) GetEmployeeByIdentity(ctx context.Context, issuer, subject string) (models.Employee, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.identities[issuer+" "+subject]
+	if !ok {
+		return models.Employee{}, repository.ErrNotFound
+	}
+	return r.employees[id], nil
+}
+
+func (r *TestRepo) LinkIdentity(ctx context.Context, employeeID int, issuer, subject, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := issuer + " " + subject
+	if _, ok := r.identities[key]; ok {
+		return nil
+	}
+	r.identities[key] = employeeID
+
+	emp := r.employees[employeeID]
+	emp.PasswordHash = ""
+	r.employees[employeeID] = emp
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.EmployeeID == employeeID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *TestRepo) SetInitialPassword(ctx context.Context, employeeID int, passwordHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	emp, ok := r.employees[employeeID]
+	if !ok || emp.PasswordHash != "" {
+		return false, nil
+	}
+	for _, id := range r.identities {
+		if id == employeeID {
+			return false, nil
+		}
+	}
+	emp.PasswordHash = passwordHash
+	r.employees[employeeID] = emp
+	return true, nil
+}
+
+func (r *TestRepo) GetEmployeeByID(ctx context.Context, id int) (models.Employee, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	emp, ok := r.employees[id]
@@ -66,28 +150,209 @@ func (r *TestRepo) GetEmployeeByID(id int) (models.Employee, error) {
 	return emp, nil
 }
 
-func (r *TestRepo) BuyMerch(employeeID int, merchName string, quantity int) error {
-	const price = 80
-	if merchName != "t-shirt" {
-		return repository.ErrInvalidMerch
+type testRefreshToken struct {
+	sessionID string
+	expiresAt time.Time
+	used      bool
+}
+
+func (r *TestRepo) CreateSession(ctx context.Context, employeeID int, sessionID, refreshTokenHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[sessionID] = &models.Session{ID: sessionID, EmployeeID: employeeID, CreatedAt: time.Now()}
+	r.refreshTokens[refreshTokenHash] = &testRefreshToken{sessionID: sessionID, expiresAt: expiresAt}
+	return nil
+}
+
+func (r *TestRepo) RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string, expiresAt time.Time) (models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.refreshTokens[refreshTokenHash]
+	if !ok {
+		return models.Session{}, repository.ErrNotFound
+	}
+	session := r.sessions[token.sessionID]
+	switch {
+	case session.RevokedAt != nil:
+		return models.Session{}, repository.ErrSessionRevoked
+	case token.used:
+		now := time.Now()
+		session.RevokedAt = &now
+		return models.Session{}, repository.ErrTokenReused
+	case !token.expiresAt.After(time.Now()):
+		return models.Session{}, repository.ErrTokenExpired
+	}
+	token.used = true
+	r.refreshTokens[newRefreshTokenHash] = &testRefreshToken{sessionID: session.ID, expiresAt: expiresAt}
+	return *session, nil
+}
+
+func (r *TestRepo) RevokeSession(ctx context.Context, sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[sessionID]; ok && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+func (r *TestRepo) SessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[sessionID]
+	return !ok || session.RevokedAt != nil, nil
+}
+
+func (r *TestRepo) SetEmployeeRole(ctx context.Context, actorID, employeeID int, role string) error {
+	if !models.ValidRole(role) {
+		return repository.ErrInvalidRole
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	emp, ok := r.employees[employeeID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	emp.Role = role
+	r.employees[employeeID] = emp
+	return nil
+}
+
+func (r *TestRepo) ListRoleChanges(ctx context.Context, employeeID int) ([]models.RoleChange, error) {
+	return []models.RoleChange{}, nil
+}
+
+func (r *TestRepo) ListMerchItems(ctx context.Context) ([]models.MerchItem, error) {
+	return []models.MerchItem{
+		{Name: "t-shirt", Title: "T-shirt", Price: 80, Active: true},
+		{Name: "hoody", Title: "Hoody", Price: 300, Active: true},
+		{Name: "socks", Title: "Socks", Price: 10, Active: true},
+	}, nil
+}
+
+func (r *TestRepo) GetMerchItem(ctx context.Context, name string) (models.MerchItem, error) {
+	items, _ := r.ListMerchItems(ctx)
+	for _, item := range items {
+		if item.Name == name {
+			return item, nil
+		}
+	}
+	return models.MerchItem{}, repository.ErrInvalidMerch
+}
+
+// merchItem ищет товар, доступный для покупки
+func (r *TestRepo) merchItem(ctx context.Context, name string) (models.MerchItem, error) {
+	item, err := r.GetMerchItem(ctx, name)
+	if err != nil {
+		return item, err
+	}
+	if !item.Active {
+		return models.MerchItem{}, repository.ErrInvalidMerch
+	}
+	return item, nil
+}
+
+func (r *TestRepo) BuyMerch(ctx context.Context, employeeID int, merchName string, quantity int) (models.Purchase, int, error) {
+	item, err := r.merchItem(ctx, merchName)
+	if err != nil {
+		return models.Purchase{}, 0, err
+	}
+	purchase := models.Purchase{
+		EmployeeID: employeeID,
+		MerchName:  merchName,
+		Price:      item.Price,
+		Quantity:   quantity,
+		CreatedAt:  time.Now(),
 	}
-	total := price * quantity
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	emp, ok := r.employees[employeeID]
 	if !ok {
+		return models.Purchase{}, 0, repository.ErrNotFound
+	}
+	if emp.CoinBalance < purchase.Total() {
+		return models.Purchase{}, 0, repository.ErrInsufficientFunds
+	}
+	emp.CoinBalance -= purchase.Total()
+	r.employees[employeeID] = emp
+	return purchase, emp.CoinBalance, nil
+}
+
+func (r *TestRepo) AddToCart(ctx context.Context, employeeID int, merchName string, quantity int) error {
+	if _, err := r.merchItem(ctx, merchName); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.carts[employeeID] == nil {
+		r.carts[employeeID] = make(map[string]int)
+	}
+	r.carts[employeeID][merchName] += quantity
+	return nil
+}
+
+func (r *TestRepo) RemoveFromCart(ctx context.Context, employeeID int, merchName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.carts[employeeID][merchName]; !ok {
 		return repository.ErrNotFound
 	}
+	delete(r.carts[employeeID], merchName)
+	return nil
+}
+
+func (r *TestRepo) GetCart(ctx context.Context, employeeID int) ([]models.CartItem, error) {
+	r.mu.Lock()
+	lines := make(map[string]int, len(r.carts[employeeID]))
+	for name, quantity := range r.carts[employeeID] {
+		lines[name] = quantity
+	}
+	r.mu.Unlock()
+
+	cart := []models.CartItem{}
+	for name, quantity := range lines {
+		item, err := r.merchItem(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		cart = append(cart, models.CartItem{MerchName: name, Title: item.Title, Price: item.Price, Quantity: quantity, Available: true})
+	}
+	return cart, nil
+}
+
+func (r *TestRepo) Checkout(ctx context.Context, employeeID int) ([]models.Purchase, int, error) {
+	cart, err := r.GetCart(ctx, employeeID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(cart) == 0 {
+		return nil, 0, repository.ErrEmptyCart
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	emp, ok := r.employees[employeeID]
+	if !ok {
+		return nil, 0, repository.ErrNotFound
+	}
+	total := 0
+	purchases := []models.Purchase{}
+	for _, line := range cart {
+		total += line.Subtotal()
+		purchases = append(purchases, models.Purchase{EmployeeID: employeeID, MerchName: line.MerchName, Price: line.Price, Quantity: line.Quantity})
+	}
 	if emp.CoinBalance < total {
-		return repository.ErrInsufficientFunds
+		return nil, 0, repository.ErrInsufficientFunds
 	}
 	emp.CoinBalance -= total
 	r.employees[employeeID] = emp
-	return nil
+	delete(r.carts, employeeID)
+	return purchases, emp.CoinBalance, nil
 }
 
-func (r *TestRepo) TransferCoins(fromID, toID, amount int) error {
+func (r *TestRepo) TransferCoins(ctx context.Context, fromID, toID, amount int) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	from, ok := r.employees[fromID]
@@ -105,10 +370,63 @@ func (r *TestRepo) TransferCoins(fromID, toID, amount int) error {
 	to.CoinBalance += amount
 	r.employees[fromID] = from
 	r.employees[toID] = to
+
+	r.nextTransferID++
+	transferID := r.nextTransferID
+	now := time.Now()
+	r.transactions = append(r.transactions,
+		models.Transaction{EmployeeID: fromID, CounterpartyID: toID, CounterpartyUsername: to.Username, Amount: -amount, TransactionType: repository.TransactionTypeTransfer, TransferID: &transferID, CreatedAt: now},
+		models.Transaction{EmployeeID: toID, CounterpartyID: fromID, CounterpartyUsername: from.Username, Amount: amount, TransactionType: repository.TransactionTypeTransfer, TransferID: &transferID, CreatedAt: now},
+	)
 	return nil
 }
 
-func (r *TestRepo) GetWalletInfo(employeeID int) (int, []models.Transaction, error) {
+func (r *TestRepo) GrantCoins(ctx context.Context, actorID int, grants []models.Grant) ([]models.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	admin := r.employees[actorID]
+
+	balances := make(map[int]int)
+	for _, g := range grants {
+		emp, ok := r.employees[g.EmployeeID]
+		if !ok {
+			return nil, repository.ErrNotFound
+		}
+		if _, seen := balances[g.EmployeeID]; !seen {
+			balances[g.EmployeeID] = emp.CoinBalance
+		}
+		balances[g.EmployeeID] += g.Amount
+		if balances[g.EmployeeID] < 0 {
+			return nil, repository.ErrInsufficientFunds
+		}
+	}
+
+	now := time.Now()
+	transactions := make([]models.Transaction, 0, len(grants))
+	for _, g := range grants {
+		txType := repository.TransactionTypeGrant
+		if g.Amount < 0 {
+			txType = repository.TransactionTypeClawback
+		}
+		r.nextTransferID++
+		entryID := r.nextTransferID
+		t := models.Transaction{EmployeeID: g.EmployeeID, CounterpartyID: actorID, CounterpartyUsername: admin.Username, Amount: g.Amount, TransactionType: txType, TransferID: &entryID, Reason: g.Reason, CreatedAt: now}
+		r.transactions = append(r.transactions, t)
+		transactions = append(transactions, t)
+	}
+	for id, balance := range balances {
+		emp := r.employees[id]
+		emp.CoinBalance = balance
+		r.employees[id] = emp
+	}
+	return transactions, nil
+}
+
+func (r *TestRepo) ReleaseDueGrants(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+func (r *TestRepo) GetWalletInfo(ctx context.Context, employeeID int) (int, []models.Transaction, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	emp, ok := r.employees[employeeID]
@@ -116,22 +434,103 @@ func (r *TestRepo) GetWalletInfo(employeeID int) (int, []models.Transaction, err
 		return 0, nil, repository.ErrNotFound
 	}
 
-	return emp.CoinBalance, []models.Transaction{}, nil
+	transactions := []models.Transaction{}
+	for _, t := range r.employeeTransactions(employeeID) {
+		if t.TransactionType == repository.TransactionTypeTransfer {
+			transactions = append(transactions, t)
+		}
+	}
+	return emp.CoinBalance, transactions, nil
+}
+
+// employeeTransactions возвращает все операции сотрудника; вызывается под r.mu
+func (r *TestRepo) employeeTransactions(employeeID int) []models.Transaction {
+	transactions := []models.Transaction{}
+	for _, t := range r.transactions {
+		if t.EmployeeID == employeeID {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions
+}
+
+func (r *TestRepo) ListTransactions(ctx context.Context, employeeID int, filter models.TransactionFilter) ([]models.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.employees[employeeID]; !ok {
+		return nil, repository.ErrNotFound
+	}
+	transactions := r.employeeTransactions(employeeID)
+	if len(transactions) > filter.Limit {
+		transactions = transactions[:filter.Limit]
+	}
+	return transactions, nil
 }
 
-func (r *TestRepo) GetInventory(employeeID int) ([]map[string]interface{}, error) {
+func (r *TestRepo) GetInventory(ctx context.Context, employeeID int) ([]map[string]interface{}, error) {
 	return []map[string]interface{}{}, nil
 }
 
+func (r *TestRepo) VerifyBalance(ctx context.Context, employeeID int) error {
+	return nil
+}
+
+func idempotencyMapKey(employeeID int, key string) string {
+	return fmt.Sprintf("%d/%s", employeeID, key)
+}
+
+func (r *TestRepo) ReserveIdempotencyKey(ctx context.Context, employeeID int, key, fingerprint string, retention time.Duration) (models.IdempotencyRecord, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if record, ok := r.idempotency[idempotencyMapKey(employeeID, key)]; ok && time.Since(record.CreatedAt) < retention {
+		return record, false, nil
+	}
+	record := models.IdempotencyRecord{Key: key, Fingerprint: fingerprint, CreatedAt: time.Now()}
+	r.idempotency[idempotencyMapKey(employeeID, key)] = record
+	return record, true, nil
+}
+
+func (r *TestRepo) CompleteIdempotencyKey(ctx context.Context, employeeID int, key string, statusCode int, responseBody []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record := r.idempotency[idempotencyMapKey(employeeID, key)]
+	record.Completed = true
+	record.StatusCode = statusCode
+	record.ResponseBody = responseBody
+	r.idempotency[idempotencyMapKey(employeeID, key)] = record
+	return nil
+}
+
+func (r *TestRepo) ReleaseIdempotencyKey(ctx context.Context, employeeID int, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.idempotency, idempotencyMapKey(employeeID, key))
+	return nil
+}
+
+func (r *TestRepo) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	purged := 0
+	for mapKey, record := range r.idempotency {
+		if record.CreatedAt.Before(before) {
+			delete(r.idempotency, mapKey)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
 // Сценарий покупки мерча
 func TestE2E_BuyMerch(t *testing.T) {
 	repo := NewTestRepo()
-	handler := handlers.NewHandler(repo, "test_secret")
+	handler := handlers.NewHandler(repo, testTokens)
 
 	router := gin.Default()
+	router.Use(middleware.ErrorHandler())
 	router.POST("/api/auth", handler.Auth)
 	apiGroup := router.Group("/api")
-	apiGroup.Use(middleware.JWTAuthMiddleware("test_secret"))
+	apiGroup.Use(middleware.JWTAuthMiddleware(testTokens, repo))
 	{
 		apiGroup.GET("/buy/:item", handler.BuyItem)
 	}
@@ -161,12 +560,13 @@ func TestE2E_BuyMerch(t *testing.T) {
 	assert.NoError(t, err)
 	defer resp.Body.Close()
 
-	var buyResp map[string]string
+	var buyResp map[string]interface{}
 	err = json.NewDecoder(resp.Body).Decode(&buyResp)
 	assert.NoError(t, err)
 	assert.Equal(t, "purchase successful", buyResp["message"])
+	assert.Equal(t, float64(920), buyResp["balance"])
 
-	buyer, err := repo.GetEmployeeByUsername("buyer")
+	buyer, err := repo.GetEmployeeByUsername(context.Background(), "buyer")
 	assert.NoError(t, err)
 	assert.Equal(t, 920, buyer.CoinBalance, "Баланс должен уменьшиться на стоимость покупки")
 }
@@ -174,13 +574,15 @@ func TestE2E_BuyMerch(t *testing.T) {
 // Сценарий передачи монет
 func TestE2E_SendCoin(t *testing.T) {
 	repo := NewTestRepo()
-	handler := handlers.NewHandler(repo, "test_secret")
+	handler := handlers.NewHandler(repo, testTokens)
 
 	router := gin.Default()
+	router.Use(middleware.ErrorHandler())
 	router.POST("/api/auth", handler.Auth)
 	apiGroup := router.Group("/api")
-	apiGroup.Use(middleware.JWTAuthMiddleware("test_secret"))
+	apiGroup.Use(middleware.JWTAuthMiddleware(testTokens, repo))
 	{
+		apiGroup.GET("/info", handler.GetInfo)
 		apiGroup.POST("/sendCoin", handler.SendCoin)
 	}
 	ts := httptest.NewServer(router)
@@ -211,7 +613,7 @@ func TestE2E_SendCoin(t *testing.T) {
 	var recipientResp map[string]string
 	err = json.NewDecoder(resp.Body).Decode(&recipientResp)
 	assert.NoError(t, err)
-	_, err = repo.GetEmployeeByUsername("recipient")
+	_, err = repo.GetEmployeeByUsername(context.Background(), "recipient")
 	assert.NoError(t, err)
 
 	sendPayload := map[string]interface{}{
@@ -233,10 +635,456 @@ func TestE2E_SendCoin(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "transfer successful", sendResp["message"])
 
-	sender, err := repo.GetEmployeeByUsername("sender")
+	sender, err := repo.GetEmployeeByUsername(context.Background(), "sender")
 	assert.NoError(t, err)
-	recipient, err := repo.GetEmployeeByUsername("recipient")
+	recipient, err := repo.GetEmployeeByUsername(context.Background(), "recipient")
 	assert.NoError(t, err)
 	assert.Equal(t, 900, sender.CoinBalance, "Баланс отправителя должен уменьшиться на сумму перевода")
 	assert.Equal(t, 1100, recipient.CoinBalance, "Баланс получателя должен увеличиться на сумму перевода")
+
+	getHistory := func(token string) map[string][]map[string]interface{} {
+		req, err := http.NewRequest("GET", ts.URL+"/api/info", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		var info struct {
+			CoinHistory map[string][]map[string]interface{} `json:"coinHistory"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&info)
+		assert.NoError(t, err)
+		return info.CoinHistory
+	}
+
+	senderHistory := getHistory(senderToken)
+	assert.Len(t, senderHistory["sent"], 1, "перевод должен попасть в отправленные у отправителя")
+	assert.Empty(t, senderHistory["received"], "у отправителя не должно быть полученных переводов")
+	if len(senderHistory["sent"]) == 1 {
+		assert.Equal(t, float64(100), senderHistory["sent"][0]["amount"])
+		assert.Equal(t, "recipient", senderHistory["sent"][0]["toUser"])
+	}
+
+	recipientHistory := getHistory(recipientResp["token"])
+	assert.Len(t, recipientHistory["received"], 1, "перевод должен попасть в полученные у получателя")
+	assert.Empty(t, recipientHistory["sent"], "у получателя не должно быть отправленных переводов")
+	if len(recipientHistory["received"]) == 1 {
+		assert.Equal(t, float64(100), recipientHistory["received"][0]["amount"])
+		assert.Equal(t, "sender", recipientHistory["received"][0]["fromUser"])
+	}
+}
+
+// Сценарий повторного входа: верный пароль принимается, неверный отклоняется
+func TestE2E_AuthPassword(t *testing.T) {
+	repo := NewTestRepo()
+	handler := handlers.NewHandler(repo, testTokens)
+
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth", handler.Auth)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	login := func(password string) int {
+		body, _ := json.Marshal(map[string]string{
+			"username": "owner",
+			"password": password,
+		})
+		resp, err := http.Post(ts.URL+"/api/auth", "application/json", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, login("secret"), "первый вход регистрирует сотрудника")
+	assert.Equal(t, http.StatusOK, login("secret"), "повторный вход с верным паролем должен проходить")
+	assert.Equal(t, http.StatusUnauthorized, login("not-secret"), "вход с чужим паролем должен отклоняться")
+}
+
+// Сценарий обновления: сотрудник, созданный до появления паролей, задаёт пароль первым входом
+func TestE2E_AuthUpgradedEmployee(t *testing.T) {
+	repo := NewTestRepo()
+	handler := handlers.NewHandler(repo, testTokens)
+
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth", handler.Auth)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Так выглядят сотрудники после миграции 0002 и учётные записи SSO
+	legacy, err := repo.CreateEmployee(context.Background(), "legacy", "")
+	assert.NoError(t, err)
+	sso, err := repo.CreateEmployee(context.Background(), "sso@corp.com", "")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.LinkIdentity(context.Background(), sso.ID, "https://idp", "sub-1", "sso@corp.com"))
+
+	login := func(username, password string) int {
+		body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+		resp, err := http.Post(ts.URL+"/api/auth", "application/json", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, login("legacy", "secret"), "первый вход задаёт пароль")
+	assert.Equal(t, http.StatusOK, login("legacy", "secret"))
+	assert.Equal(t, http.StatusUnauthorized, login("legacy", "guess"), "после этого пароль проверяется")
+	emp, err := repo.GetEmployeeByID(context.Background(), legacy.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, emp.CoinBalance, "вход не создаёт новую учётную запись")
+
+	assert.Equal(t, http.StatusUnauthorized, login("sso@corp.com", "secret"), "учётной записи SSO пароль не задаётся")
+}
+
+// Сценарий оформления корзины: либо покупается всё, либо ничего
+func TestE2E_CartCheckout(t *testing.T) {
+	repo := NewTestRepo()
+	handler := handlers.NewHandler(repo, testTokens)
+
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth", handler.Auth)
+	apiGroup := router.Group("/api")
+	apiGroup.Use(middleware.JWTAuthMiddleware(testTokens, repo))
+	{
+		apiGroup.GET("/cart", handler.GetCart)
+		apiGroup.POST("/cart/items", handler.AddCartItem)
+		apiGroup.DELETE("/cart/items/:item", handler.RemoveCartItem)
+		apiGroup.POST("/cart/checkout", handler.Checkout)
+	}
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	authBody, _ := json.Marshal(map[string]string{"username": "shopper", "password": "pass"})
+	resp, err := http.Post(ts.URL+"/api/auth", "application/json", bytes.NewBuffer(authBody))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	var authResp map[string]string
+	err = json.NewDecoder(resp.Body).Decode(&authResp)
+	assert.NoError(t, err)
+	token := authResp["token"]
+
+	client := &http.Client{}
+	call := func(method, path string, payload interface{}) (int, map[string]interface{}) {
+		var body bytes.Buffer
+		if payload != nil {
+			_ = json.NewEncoder(&body).Encode(payload)
+		}
+		req, err := http.NewRequest(method, ts.URL+path, &body)
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		var out map[string]interface{}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	code, _ := call("POST", "/api/cart/items", map[string]interface{}{"item": "hoody", "quantity": 4})
+	assert.Equal(t, http.StatusOK, code)
+	code, cart := call("POST", "/api/cart/items", map[string]interface{}{"item": "socks", "quantity": 2})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(1220), cart["total"])
+
+	code, _ = call("POST", "/api/cart/checkout", nil)
+	assert.Equal(t, http.StatusBadRequest, code, "корзина дороже баланса не должна оформляться")
+	shopper, err := repo.GetEmployeeByUsername(context.Background(), "shopper")
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, shopper.CoinBalance, "при неудачном оформлении баланс не должен меняться")
+
+	code, cart = call("DELETE", "/api/cart/items/hoody", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(20), cart["total"])
+	code, _ = call("POST", "/api/cart/items", map[string]interface{}{"item": "hoody"})
+	assert.Equal(t, http.StatusOK, code)
+
+	code, checkout := call("POST", "/api/cart/checkout", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(320), checkout["charged"])
+	assert.Equal(t, float64(680), checkout["balance"])
+
+	code, cart = call("GET", "/api/cart", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, cart["items"], "после оформления корзина должна быть пустой")
+}
+
+// Сценарий повторной отправки перевода с тем же ключом идемпотентности
+func TestE2E_SendCoinIdempotency(t *testing.T) {
+	repo := NewTestRepo()
+	handler := handlers.NewHandler(repo, testTokens)
+
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth", handler.Auth)
+	apiGroup := router.Group("/api")
+	apiGroup.Use(middleware.JWTAuthMiddleware(testTokens, repo))
+	{
+		apiGroup.POST("/sendCoin", middleware.Idempotency(repo, time.Hour), handler.SendCoin)
+	}
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	var senderToken string
+	for _, username := range []string{"sender", "recipient"} {
+		body, _ := json.Marshal(map[string]string{"username": username, "password": "pass"})
+		resp, err := http.Post(ts.URL+"/api/auth", "application/json", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		var authResp map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&authResp)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		if username == "sender" {
+			senderToken = authResp["token"]
+		}
+	}
+
+	client := &http.Client{}
+	send := func(key string, amount int) int {
+		body, _ := json.Marshal(map[string]interface{}{"toUser": "recipient", "amount": amount})
+		req, err := http.NewRequest("POST", ts.URL+"/api/sendCoin", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+senderToken)
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, send("retry-me", 100))
+	assert.Equal(t, http.StatusOK, send("retry-me", 100), "повтор должен вернуть исходный ответ")
+	assert.Equal(t, http.StatusUnprocessableEntity, send("retry-me", 200), "ключ с другим телом запроса должен отклоняться")
+
+	sender, err := repo.GetEmployeeByUsername(context.Background(), "sender")
+	assert.NoError(t, err)
+	assert.Equal(t, 900, sender.CoinBalance, "монеты должны списаться только один раз")
+}
+
+// Сценарий смены роли: новая роль действует только в новом токене
+func TestE2E_RoleChangeTakesEffectOnNextToken(t *testing.T) {
+	repo := NewTestRepo()
+	handler := handlers.NewHandler(repo, testTokens)
+
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth", handler.Auth)
+	apiGroup := router.Group("/api")
+	apiGroup.Use(middleware.JWTAuthMiddleware(testTokens, repo))
+	{
+		adminGroup := apiGroup.Group("/admin")
+		adminGroup.Use(middleware.RequireRole(models.RoleFinanceAdmin))
+		adminGroup.GET("/employees/:username/role-changes", handler.ListRoleChanges)
+	}
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	login := func() string {
+		body, _ := json.Marshal(map[string]string{"username": "finance", "password": "pass"})
+		resp, err := http.Post(ts.URL+"/api/auth", "application/json", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var authResp map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&authResp)
+		assert.NoError(t, err)
+		return authResp["token"]
+	}
+	client := &http.Client{}
+	audit := func(token string) int {
+		req, err := http.NewRequest("GET", ts.URL+"/api/admin/employees/finance/role-changes", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	oldToken := login()
+	assert.Equal(t, http.StatusForbidden, audit(oldToken))
+
+	employee, err := repo.GetEmployeeByUsername(context.Background(), "finance")
+	assert.NoError(t, err)
+	err = repo.SetEmployeeRole(context.Background(), employee.ID, employee.ID, models.RoleFinanceAdmin)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusForbidden, audit(oldToken), "старый токен сохраняет прежнюю роль")
+	assert.Equal(t, http.StatusOK, audit(login()), "новый токен получает новую роль")
+}
+
+// Сценарий начислений: пакет применяется целиком или не применяется вовсе
+func TestE2E_AdminGrants(t *testing.T) {
+	repo := NewTestRepo()
+	handler := handlers.NewHandler(repo, testTokens)
+
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth", handler.Auth)
+	apiGroup := router.Group("/api")
+	apiGroup.Use(middleware.JWTAuthMiddleware(testTokens, repo))
+	{
+		apiGroup.GET("/info", handler.GetInfo)
+		apiGroup.GET("/transactions", handler.ListTransactions)
+		adminGroup := apiGroup.Group("/admin")
+		adminGroup.Use(middleware.RequireRole(models.RoleFinanceAdmin))
+		adminGroup.POST("/grants", handler.GrantCoins)
+	}
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	login := func(username string) string {
+		body, _ := json.Marshal(map[string]string{"username": username, "password": "pass"})
+		resp, err := http.Post(ts.URL+"/api/auth", "application/json", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var authResp map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&authResp)
+		assert.NoError(t, err)
+		return authResp["token"]
+	}
+	login("alice")
+	login("bob")
+	login("boss")
+	boss, err := repo.GetEmployeeByUsername(context.Background(), "boss")
+	assert.NoError(t, err)
+	err = repo.SetEmployeeRole(context.Background(), boss.ID, boss.ID, models.RoleFinanceAdmin)
+	assert.NoError(t, err)
+	bossToken := login("boss")
+
+	client := &http.Client{}
+	grant := func(payload string) int {
+		req, err := http.NewRequest("POST", ts.URL+"/api/admin/grants", bytes.NewBufferString(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+bossToken)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+	balance := func(username string) int {
+		emp, err := repo.GetEmployeeByUsername(context.Background(), username)
+		assert.NoError(t, err)
+		return emp.CoinBalance
+	}
+
+	code := grant(`{"reason": "hackathon", "grants": [{"username": "alice", "amount": 300}, {"username": "bob", "amount": -200}]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1300, balance("alice"))
+	assert.Equal(t, 800, balance("bob"))
+
+	code = grant(`{"reason": "typo", "grants": [{"username": "alice", "amount": 100}, {"username": "bob", "amount": -5000}]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, 1300, balance("alice"), "неудачный пакет не должен начислить монеты никому")
+	assert.Equal(t, 800, balance("bob"))
+
+	aliceToken := login("alice")
+	get := func(path string, target interface{}) {
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+aliceToken)
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(target))
+	}
+
+	// В coinHistory попадают только переводы между сотрудниками
+	var info struct {
+		CoinHistory struct {
+			Received []map[string]interface{} `json:"received"`
+			Sent     []map[string]interface{} `json:"sent"`
+		} `json:"coinHistory"`
+	}
+	get("/api/info", &info)
+	assert.Empty(t, info.CoinHistory.Received, "начисления и бонусы не выдаются за переводы")
+	assert.Empty(t, info.CoinHistory.Sent)
+
+	var history struct {
+		Transactions []map[string]interface{} `json:"transactions"`
+	}
+	get("/api/transactions", &history)
+	types := map[string]interface{}{}
+	for _, tx := range history.Transactions {
+		types[tx["type"].(string)] = tx["counterparty"]
+	}
+	assert.Equal(t, map[string]interface{}{"welcome": "", "grant": "boss"}, types, "начисление приписано администратору")
+	for _, tx := range history.Transactions {
+		if tx["type"] == "grant" {
+			assert.Equal(t, "hackathon", tx["reason"], "причина начисления видна в истории")
+		}
+	}
+}
+
+// Сценарий сессии: обновление токенов, обнаружение повторного refresh токена и выход
+func TestE2E_RefreshAndLogout(t *testing.T) {
+	repo := NewTestRepo()
+	handler := handlers.NewHandler(repo, testTokens)
+
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth", handler.Auth)
+	router.POST("/api/auth/refresh", handler.Refresh)
+	apiGroup := router.Group("/api")
+	apiGroup.Use(middleware.JWTAuthMiddleware(testTokens, repo))
+	{
+		apiGroup.POST("/auth/logout", handler.Logout)
+		apiGroup.GET("/info", handler.GetInfo)
+	}
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := &http.Client{}
+	post := func(path, token string, payload interface{}) (int, map[string]string) {
+		body, _ := json.Marshal(payload)
+		req, err := http.NewRequest("POST", ts.URL+path, bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		result := map[string]string{}
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+	info := func(token string) int {
+		req, err := http.NewRequest("GET", ts.URL+"/api/info", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	code, login := post("/api/auth", "", map[string]string{"username": "alice", "password": "pass"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, login["refreshToken"])
+
+	code, refreshed := post("/api/auth/refresh", "", map[string]string{"refreshToken": login["refreshToken"]})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, http.StatusOK, info(refreshed["token"]))
+
+	// Повторное использование старого refresh токена отзывает всю сессию
+	code, _ = post("/api/auth/refresh", "", map[string]string{"refreshToken": login["refreshToken"]})
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, http.StatusUnauthorized, info(refreshed["token"]), "access токен отозванной сессии не принимается")
+	code, _ = post("/api/auth/refresh", "", map[string]string{"refreshToken": refreshed["refreshToken"]})
+	assert.Equal(t, http.StatusUnauthorized, code, "новый refresh токен отозванной сессии тоже не принимается")
+
+	// Выход отзывает только текущую сессию
+	_, first := post("/api/auth", "", map[string]string{"username": "alice", "password": "pass"})
+	_, second := post("/api/auth", "", map[string]string{"username": "alice", "password": "pass"})
+	code, _ = post("/api/auth/logout", first["token"], nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, http.StatusUnauthorized, info(first["token"]))
+	assert.Equal(t, http.StatusOK, info(second["token"]))
 }
@@ -0,0 +1,87 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"merch-store/internal/middleware"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"merch-store/internal/handlers"
+	"merch-store/internal/sso"
+	"merch-store/internal/sso/ssotest"
+)
+
+// Сценарий входа через OIDC с тестовым провайдером идентификации
+func TestE2E_OIDCLogin(t *testing.T) {
+	repo := NewTestRepo()
+	handler := handlers.NewHandler(repo, testTokens)
+
+	router := gin.Default()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/api/auth", handler.Auth)
+	router.GET("/api/auth/oidc/login", handler.OIDCLogin)
+	router.GET("/api/auth/oidc/callback", handler.OIDCCallback)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	idp := ssotest.NewServer("merch-store", "secret")
+	defer idp.Close()
+	provider, err := sso.NewProvider(context.Background(), sso.Config{
+		IssuerURL:    idp.URL,
+		ClientID:     "merch-store",
+		ClientSecret: "secret",
+		RedirectURL:  ts.URL + "/api/auth/oidc/callback",
+	})
+	assert.NoError(t, err)
+	handler.SetOIDCProvider(provider, ts.URL+"/api/auth/oidc/callback")
+
+	// Браузер проходит все перенаправления: сервис -> провайдер -> сервис
+	ssoLogin := func(identity ssotest.Identity) int {
+		idp.SetIdentity(identity)
+		jar, _ := cookiejar.New(nil)
+		client := &http.Client{Jar: jar}
+		resp, err := client.Get(ts.URL + "/api/auth/oidc/login")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var tokens map[string]string
+		_ = json.NewDecoder(resp.Body).Decode(&tokens)
+		if resp.StatusCode == http.StatusOK {
+			assert.NotEmpty(t, tokens["token"])
+			assert.NotEmpty(t, tokens["refreshToken"])
+		}
+		return resp.StatusCode
+	}
+	passwordLogin := func(username, password string) int {
+		body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+		resp, err := http.Post(ts.URL+"/api/auth", "application/json", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Первый вход создаёт сотрудника с приветственным бонусом
+	assert.Equal(t, http.StatusOK, ssoLogin(ssotest.Identity{Subject: "sub-bob", Email: "bob@example.com", EmailVerified: true}))
+	bob, err := repo.GetEmployeeByUsername(context.Background(), "bob@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, bob.CoinBalance)
+	assert.Equal(t, http.StatusUnauthorized, passwordLogin("bob@example.com", "anything"), "у SSO-сотрудника нет пароля")
+
+	// Повторный вход по тому же subject находит того же сотрудника, даже если email сменился
+	assert.Equal(t, http.StatusOK, ssoLogin(ssotest.Identity{Subject: "sub-bob", Email: "robert@example.com", EmailVerified: true}))
+	_, err = repo.GetEmployeeByUsername(context.Background(), "robert@example.com")
+	assert.Error(t, err, "новый сотрудник не должен создаваться")
+
+	// Существующая учётная запись с тем же email привязывается, вход по паролю отключается
+	assert.Equal(t, http.StatusOK, passwordLogin("carol@example.com", "pass"))
+	assert.Equal(t, http.StatusOK, ssoLogin(ssotest.Identity{Subject: "sub-carol", Email: "carol@example.com", EmailVerified: true}))
+	assert.Equal(t, http.StatusUnauthorized, passwordLogin("carol@example.com", "pass"))
+
+	// Без подтверждённого email новую учётную запись не создаём
+	assert.Equal(t, http.StatusForbidden, ssoLogin(ssotest.Identity{Subject: "sub-dave", Email: "dave@example.com"}))
+}